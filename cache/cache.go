@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tempPrefix marks files being written, they are removed when a cache is opened
+const tempPrefix = ".tmp-"
+
+// Cache keeps derived images on disk, grouped by the path of their source.
+// The least recently used entries are evicted once the cache holds more than MaxBytes
+type Cache struct {
+	Dir      string
+	MaxBytes int64
+
+	mu      sync.Mutex
+	size    int64
+	order   *list.List // of *entry, most recently used first
+	entries map[string]*list.Element
+}
+
+type entry struct {
+	id   string
+	size int64
+}
+
+// New opens the cache in dir, the entries left by a previous run are kept in order of their last use
+func New(dir string, maxBytes int64) (*Cache, error) {
+	c := &Cache{
+		Dir:      dir,
+		MaxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	type found struct {
+		entry
+		used time.Time
+	}
+	var files []found
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if strings.HasPrefix(info.Name(), tempPrefix) {
+			return os.Remove(path)
+		}
+		id, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, found{entry{filepath.ToSlash(id), info.Size()}, info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].used.After(files[j].used)
+	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range files {
+		e := files[i].entry
+		c.entries[e.id] = c.order.PushBack(&e)
+		c.size += e.size
+	}
+	c.evict()
+	return c, nil
+}
+
+// Get return the content cached for key of the source path
+func (c *Cache) Get(path, key string) ([]byte, bool) {
+	id := entryID(path, key)
+	c.mu.Lock()
+	elem, ok := c.entries[id]
+	if ok {
+		c.order.MoveToFront(elem)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(c.filePath(id))
+	if err != nil {
+		c.remove(id)
+		return nil, false
+	}
+	// the modification time keeps the order of use for the next run
+	now := time.Now()
+	os.Chtimes(c.filePath(id), now, now)
+	return data, true
+}
+
+// Put caches data for key of the source path, data larger than the cache is not kept
+func (c *Cache) Put(path, key string, data []byte) error {
+	size := int64(len(data))
+	if size > c.MaxBytes {
+		return nil
+	}
+	id := entryID(path, key)
+	file := c.filePath(id)
+	dir := filepath.Dir(file)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	temp, err := ioutil.TempFile(dir, tempPrefix)
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	// the entry is moved in and registered under c.mu, so an Invalidate can not remove it in between
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		err = os.Rename(temp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	if elem, ok := c.entries[id]; ok {
+		c.drop(elem)
+	}
+	c.entries[id] = c.order.PushFront(&entry{id, size})
+	c.size += size
+	c.evict()
+	return nil
+}
+
+// Invalidate removes every entry of the source path
+func (c *Cache) Invalidate(path string) error {
+	dir := hash(path)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, elem := range c.entries {
+		if strings.HasPrefix(id, dir+"/") {
+			c.drop(elem)
+		}
+	}
+	return os.RemoveAll(filepath.Join(c.Dir, dir))
+}
+
+// Size return the bytes held by the cache
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// evict removes the least recently used entries until the cache fits in MaxBytes, c.mu must be held
+func (c *Cache) evict() {
+	for c.size > c.MaxBytes {
+		elem := c.order.Back()
+		c.drop(elem)
+		os.Remove(c.filePath(elem.Value.(*entry).id))
+	}
+}
+
+// drop forgets an entry, c.mu must be held
+func (c *Cache) drop(elem *list.Element) {
+	e := elem.Value.(*entry)
+	c.order.Remove(elem)
+	delete(c.entries, e.id)
+	c.size -= e.size
+}
+
+// remove forgets an entry which can not be read
+func (c *Cache) remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[id]; ok {
+		c.drop(elem)
+	}
+	os.Remove(c.filePath(id))
+}
+
+func (c *Cache) filePath(id string) string {
+	return filepath.Join(c.Dir, filepath.FromSlash(id))
+}
+
+// entryID is the path of an entry relative to the cache directory
+func entryID(path, key string) string {
+	return hash(path) + "/" + hash(key)
+}
+
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCache(t *testing.T, maxBytes int64) (*Cache, func()) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := New(dir, maxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, func() { os.RemoveAll(dir) }
+}
+
+func TestGetPut(t *testing.T) {
+	c, cleanup := newTestCache(t, 100)
+	defer cleanup()
+	_, ok := c.Get("a.png", "k")
+	assert.False(t, ok)
+	assert.NoError(t, c.Put("a.png", "k", []byte("hello")))
+	data, ok := c.Get("a.png", "k")
+	assert.True(t, ok)
+	assert.Equal(t, "hello", string(data))
+	_, ok = c.Get("b.png", "k")
+	assert.False(t, ok)
+
+	assert.NoError(t, c.Put("a.png", "k", []byte("hi")))
+	assert.Equal(t, int64(2), c.Size())
+	assert.NoError(t, c.Put("a.png", "large", make([]byte, 101)))
+	_, ok = c.Get("a.png", "large")
+	assert.False(t, ok)
+}
+
+func TestEvictLeastRecentlyUsed(t *testing.T) {
+	c, cleanup := newTestCache(t, 10)
+	defer cleanup()
+	c.Put("a.png", "1", []byte("1234"))
+	c.Put("a.png", "2", []byte("1234"))
+	c.Get("a.png", "1")
+	c.Put("a.png", "3", []byte("1234"))
+
+	_, ok := c.Get("a.png", "2")
+	assert.False(t, ok, "least recently used is evicted")
+	_, ok = c.Get("a.png", "1")
+	assert.True(t, ok)
+	_, ok = c.Get("a.png", "3")
+	assert.True(t, ok)
+	assert.Equal(t, int64(8), c.Size())
+}
+
+func TestInvalidate(t *testing.T) {
+	c, cleanup := newTestCache(t, 100)
+	defer cleanup()
+	c.Put("a.png", "1", []byte("a1"))
+	c.Put("a.png", "2", []byte("a2"))
+	c.Put("b.png", "1", []byte("b1"))
+	assert.NoError(t, c.Invalidate("a.png"))
+	_, ok := c.Get("a.png", "1")
+	assert.False(t, ok)
+	_, ok = c.Get("b.png", "1")
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), c.Size())
+	assert.NoError(t, c.Invalidate("missing.png"))
+}
+
+func TestPutDuringInvalidate(t *testing.T) {
+	c, cleanup := newTestCache(t, 1<<20)
+	defer cleanup()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				c.Put("a.png", strconv.Itoa(i*50+j), []byte("data"))
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				c.Invalidate("a.png")
+			}
+		}()
+	}
+	wg.Wait()
+	var onDisk int64
+	filepath.Walk(c.Dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			onDisk += info.Size()
+		}
+		return nil
+	})
+	assert.Equal(t, onDisk, c.Size(), "the size counts only entries on disk")
+}
+
+func TestReopen(t *testing.T) {
+	c, cleanup := newTestCache(t, 100)
+	defer cleanup()
+	c.Put("a.png", "1", []byte("a1"))
+	c.Put("b.png", "1", []byte("b1"))
+	ioutil.WriteFile(c.Dir+"/"+tempPrefix+"left", []byte("partial"), 0644)
+
+	reopened, err := New(c.Dir, 100)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(4), reopened.Size())
+		data, ok := reopened.Get("b.png", "1")
+		assert.True(t, ok)
+		assert.Equal(t, "b1", string(data))
+	}
+	reopened, err = New(c.Dir, 2)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(2), reopened.Size())
+		_, ok := reopened.Get("b.png", "1")
+		assert.True(t, ok, "the last used entry is kept")
+	}
+}
@@ -0,0 +1,49 @@
+package database
+
+import "github.com/jinzhu/gorm"
+
+//GetBlob by its checksum
+func (db *DB) GetBlob(id string) (*Blob, error) {
+	blob := &Blob{}
+	if err := db.Where("id = ?", id).First(blob).Error; err != nil {
+		return nil, err
+	}
+	return blob, nil
+}
+
+//AcquireBlob add a reference to a blob, the blob is created if it does not exist
+func (db *DB) AcquireBlob(blob *Blob) error {
+	return db.transaction(func(tx *gorm.DB) error {
+		rs := tx.Model(&Blob{}).
+			Where("id = ?", blob.ID).
+			UpdateColumn("ref_count", gorm.Expr("ref_count + ?", 1))
+		if rs.Error != nil {
+			return rs.Error
+		}
+		if rs.RowsAffected > 0 {
+			return nil
+		}
+		blob.RefCount = 1
+		return tx.Create(blob).Error
+	})
+}
+
+//ReleaseBlob remove a reference from a blob
+//return true if no reference is left and the blob is deleted
+func (db *DB) ReleaseBlob(id string) (bool, error) {
+	var dropped bool
+	err := db.transaction(func(tx *gorm.DB) error {
+		var blob Blob
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").
+			Where("id = ?", id).
+			First(&blob).Error; err != nil {
+			return err
+		}
+		if blob.RefCount > 1 {
+			return tx.Model(&blob).UpdateColumn("ref_count", blob.RefCount-1).Error
+		}
+		dropped = true
+		return tx.Delete(&blob).Error
+	})
+	return dropped, err
+}
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Errors
+var (
+	ErrInvalidCursor = errors.New("cursor-invalid")
+)
+
+// Cursor points to the position after a file in a list of files ordered by Orders.
+// Values are the order columns of that file, ID breaks ties between equal values.
+type Cursor struct {
+	Orders []Order  `json:"o"`
+	Values []string `json:"v"`
+	ID     uint     `json:"id"`
+}
+
+// NewCursor pointing after file
+func NewCursor(orders []Order, file *File) *Cursor {
+	cursor := Cursor{Orders: orders, ID: file.ID}
+	cursor.Values = make([]string, len(orders))
+	for i, od := range orders {
+		cursor.Values[i] = file.orderValue(od.Column)
+	}
+	return &cursor
+}
+
+// DecodeCursor from its opaque representation
+func DecodeCursor(encoded string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if len(cursor.Orders) != len(cursor.Values) {
+		return nil, ErrInvalidCursor
+	}
+	for _, od := range cursor.Orders {
+		if err := od.validate(); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &cursor, nil
+}
+
+// Encode cursor to an opaque string
+func (c *Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// keys return the order of cursor, with id as the last key
+func (c *Cursor) keys() ([]Order, []string) {
+	orders := keyOrders(c.Orders)
+	values := c.Values
+	if len(orders) == len(values) {
+		return orders, values
+	}
+	id := strconv.FormatUint(uint64(c.ID), 10)
+	return orders, append(values[:len(values):len(values)], id)
+}
+
+// keyOrders return orders with id as the last key, so files with equal values keep a fixed order.
+// id follows the direction of the last order
+func keyOrders(orders []Order) []Order {
+	for _, od := range orders {
+		if od.Column == "id" {
+			return orders
+		}
+	}
+	id := Order{Column: "id"}
+	if len(orders) > 0 {
+		id.Desc = orders[len(orders)-1].Desc
+	}
+	return append(orders[:len(orders):len(orders)], id)
+}
+
+// filter files which are after the cursor
+func (c *Cursor) filter(tx *gorm.DB) *gorm.DB {
+	orders, values := c.keys()
+	var clauses []string
+	var args []interface{}
+	for i, od := range orders {
+		parts := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			parts = append(parts, "files."+orders[j].Column+" = ?")
+			args = append(args, values[j])
+		}
+		op := " > ?"
+		if od.Desc {
+			op = " < ?"
+		}
+		parts = append(parts, "files."+od.Column+op)
+		args = append(args, values[i])
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+	return tx.Where(strings.Join(clauses, " OR "), args...)
+}
+
+func (f *File) orderValue(column string) string {
+	switch column {
+	case "id":
+		return strconv.FormatUint(uint64(f.ID), 10)
+	case "created_at":
+		return f.CreatedAt.Format(time.RFC3339Nano)
+	case "updated_at":
+		return f.UpdatedAt.Format(time.RFC3339Nano)
+	case "fullname":
+		return f.Fullname
+	case "size":
+		return strconv.FormatInt(f.Size, 10)
+	case "content_type":
+		return f.ContentType
+	case "width":
+		return strconv.Itoa(f.Width)
+	case "height":
+		return strconv.Itoa(f.Height)
+	case "color_model":
+		return f.ColorModel
+	case "checksum":
+		return f.Checksum
+	}
+	return ""
+}
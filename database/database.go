@@ -9,7 +9,8 @@ import (
 //DB is database
 type DB struct {
 	*gorm.DB
-	url string
+	url     string
+	changed func(name string)
 }
 
 //New database
@@ -29,6 +30,24 @@ func NewClean(url string) *DB {
 	return db
 }
 
+//OnFileChange calls fn with the name of a file whose content is changed, renamed or deleted,
+//whichever component made the change
+func (db *DB) OnFileChange(fn func(name string)) {
+	db.changed = fn
+}
+
+// fileChanged reports a change of the file named name
+func (db *DB) fileChanged(name string) {
+	if db.changed != nil {
+		db.changed(name)
+	}
+}
+
+// withTx return db working in transaction tx
+func (db *DB) withTx(tx *gorm.DB) *DB {
+	return &DB{DB: tx, url: db.url, changed: db.changed}
+}
+
 //SetURL of database
 func (db *DB) SetURL(url string) {
 	db.url = url
@@ -50,4 +69,6 @@ func (db *DB) Teardown() {
 	db.DropTableIfExists("FileTag")
 	db.DropTableIfExists(&File{})
 	db.DropTableIfExists(&Tag{})
+	db.DropTableIfExists(&Blob{})
+	db.DropTableIfExists(&Intent{})
 }
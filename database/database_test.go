@@ -18,7 +18,7 @@ func setup() {
 
 func TestGetFiles(t *testing.T) {
 	setup()
-	files, err := db.GetFiles(make([]string, 0), 0, 10, make([]string, 0))
+	files, err := db.GetFiles(&FileQuery{Size: 10, Orders: []Order{{Column: "size", Desc: true}}})
 	if err != nil {
 		t.Error(err)
 		return
@@ -28,6 +28,80 @@ func TestGetFiles(t *testing.T) {
 	}
 }
 
+func TestCountFiles(t *testing.T) {
+	setup()
+	for _, name := range []string{"a.png", "b.png"} {
+		file := &File{Fullname: name}
+		if err := db.CreateFile(file); err != nil {
+			t.Error(err)
+			return
+		}
+		db.AddTag(file, "first")
+		db.AddTag(file, "second")
+	}
+	count, err := db.CountFiles(&FileQuery{AnyTags: []string{"first", "second"}, Orders: []Order{{Column: "id"}}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if count != 2 {
+		t.Errorf("expected 2 files, got %v", count)
+	}
+}
+
+func TestGetFilesByTags(t *testing.T) {
+	setup()
+	tags := map[string][]string{
+		"red.png":    {"red", "warm"},
+		"orange.png": {"red", "yellow", "warm"},
+		"blue.png":   {"blue", "cold"},
+		"plain.png":  {},
+	}
+	for name, fileTags := range tags {
+		file := &File{Fullname: name}
+		if err := db.CreateFile(file); err != nil {
+			t.Error(err)
+			return
+		}
+		for _, tag := range fileTags {
+			db.AddTag(file, tag)
+		}
+	}
+	cases := []struct {
+		query    FileQuery
+		expected string
+	}{
+		{FileQuery{AllTags: []string{"red", "warm", "red"}}, "[orange.png red.png]"},
+		{FileQuery{AllTags: []string{"red", "yellow"}}, "[orange.png]"},
+		{FileQuery{AnyTags: []string{"red", "warm", "cold"}}, "[blue.png orange.png red.png]"},
+		{FileQuery{NoTags: []string{"warm"}}, "[blue.png plain.png]"},
+		{FileQuery{AnyTags: []string{"warm"}, NoTags: []string{"yellow"}}, "[red.png]"},
+	}
+	for _, c := range cases {
+		c.query.Size = 10
+		c.query.Orders = []Order{{Column: "fullname"}}
+		files, err := db.GetFiles(&c.query)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		names := make([]string, len(files))
+		for i, f := range files {
+			names[i] = f.Fullname
+		}
+		if fmt.Sprint(names) != c.expected {
+			t.Errorf("expected %v, got %v", c.expected, names)
+		}
+	}
+}
+
+func TestGetFilesShouldFail(t *testing.T) {
+	setup()
+	if _, err := db.GetFiles(&FileQuery{Size: 10, Orders: []Order{{Column: "1; drop table files"}}}); err != ErrInvalidOrder {
+		t.Errorf("expected %v, got %v", ErrInvalidOrder, err)
+	}
+}
+
 func TestAddFile(t *testing.T) {
 	setup()
 	newFile := &File{Fullname: "test.txt"}
@@ -48,6 +122,268 @@ func TestAddFile(t *testing.T) {
 	}
 }
 
+func TestTagHierarchy(t *testing.T) {
+	for tag, expected := range map[string]string{
+		"campaign/2026/spring": "campaign/2026",
+		"brand:acme":           "brand",
+		"red":                  "",
+	} {
+		if parent := ParentTag(tag); parent != expected {
+			t.Errorf("expected parent %q of %q, got %q", expected, tag, parent)
+		}
+	}
+	if path := fmt.Sprint(TagPath("brand:acme/logo")); path != "[brand brand:acme brand:acme/logo]" {
+		t.Errorf("unexpected path %v", path)
+	}
+	for tag, expected := range map[string]string{
+		" campaign//2026/ ": "campaign/2026",
+		"brand : acme":      "brand:acme",
+		"/red":              "red",
+	} {
+		if normalized, err := NormalizeTag(tag); err != nil || normalized != expected {
+			t.Errorf("expected %q of %q, got %q, %v", expected, tag, normalized, err)
+		}
+	}
+	if _, err := NormalizeTag(" /:/ "); err != ErrTagInvalid {
+		t.Errorf("expected %v, got %v", ErrTagInvalid, err)
+	}
+}
+
+func TestGetFilesUnderTags(t *testing.T) {
+	setup()
+	tags := map[string]string{
+		"spring.png":  "campaign/2026/spring",
+		"2026.png":    "campaign/2026",
+		"2025.png":    "campaign/2025",
+		"acme.png":    "brand:acme",
+		"similar.png": "campaign/20260",
+	}
+	for name, tag := range tags {
+		file := &File{Fullname: name}
+		if err := db.CreateFile(file); err != nil {
+			t.Error(err)
+			return
+		}
+		db.AddTag(file, tag)
+	}
+	cases := []struct {
+		under    []string
+		expected string
+	}{
+		{[]string{"campaign/2026"}, "[2026.png spring.png]"},
+		{[]string{"campaign"}, "[2025.png 2026.png similar.png spring.png]"},
+		{[]string{"brand", "campaign/2025"}, "[2025.png acme.png]"},
+		{[]string{"campaign/20%"}, "[]"},
+	}
+	for _, c := range cases {
+		files, err := db.GetFiles(&FileQuery{UnderTags: c.under, Size: 10, Orders: []Order{{Column: "fullname"}}})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		names := make([]string, len(files))
+		for i, f := range files {
+			names[i] = f.Fullname
+		}
+		if fmt.Sprint(names) != c.expected {
+			t.Errorf("expected %v, got %v", c.expected, names)
+		}
+	}
+}
+
+func TestCursorEncoding(t *testing.T) {
+	file := &File{Model: gorm.Model{ID: 7}, Fullname: "a.png", Size: 42}
+	orders := []Order{{Column: "size", Desc: true}, {Column: "fullname"}}
+	cursor, err := DecodeCursor(NewCursor(orders, file).Encode())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if cursor.ID != 7 || cursor.Values[0] != "42" || cursor.Values[1] != "a.png" || !cursor.Orders[0].Desc {
+		t.Errorf("unexpected cursor %+v", cursor)
+	}
+
+	forged := (&Cursor{Orders: []Order{{Column: "id; drop table files"}}, Values: []string{"1"}}).Encode()
+	for _, encoded := range []string{"", "not-base64!", forged} {
+		if _, err := DecodeCursor(encoded); err != ErrInvalidCursor {
+			t.Errorf("expected %v for %q, got %v", ErrInvalidCursor, encoded, err)
+		}
+	}
+}
+
+func TestGetFilesAfterCursor(t *testing.T) {
+	setup()
+	for _, name := range []string{"c.png", "a.png", "b.png", "a.png"} {
+		if err := db.CreateFile(&File{Fullname: name}); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	query := &FileQuery{Size: 3, Orders: []Order{{Column: "fullname"}}}
+	var names []string
+	for {
+		files, err := db.GetFiles(query)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(files) == 0 {
+			break
+		}
+		for _, f := range files {
+			names = append(names, f.Fullname)
+		}
+		query.Size = 1
+		query.After = query.NextCursor(files)
+	}
+	if fmt.Sprint(names) != "[a.png a.png b.png c.png]" {
+		t.Errorf("unexpected order %v", names)
+	}
+}
+
+func TestGetFilesWithTiedValues(t *testing.T) {
+	setup()
+	for i := 0; i < 7; i++ {
+		if err := db.CreateFile(&File{Fullname: fmt.Sprintf("%d.png", i), Width: i % 2}); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	for _, desc := range []bool{false, true} {
+		query := &FileQuery{Size: 2, Orders: []Order{{Column: "width", Desc: desc}}}
+		seen := make(map[uint]int)
+		for page := 0; page < 10; page++ {
+			files, err := db.GetFiles(query)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(files) == 0 {
+				break
+			}
+			for _, f := range files {
+				seen[f.ID]++
+			}
+			query.After = query.NextCursor(files)
+		}
+		if len(seen) != 7 {
+			t.Errorf("desc %v: %d of 7 files listed", desc, len(seen))
+		}
+		for id, count := range seen {
+			if count != 1 {
+				t.Errorf("desc %v: file %d listed %d times", desc, id, count)
+			}
+		}
+	}
+}
+
+func TestKeyOrders(t *testing.T) {
+	cases := []struct {
+		orders   []Order
+		expected string
+	}{
+		{nil, "[files.id asc]"},
+		{[]Order{{Column: "width"}}, "[files.width asc files.id asc]"},
+		{[]Order{{Column: "width", Desc: true}}, "[files.width desc files.id desc]"},
+		{[]Order{{Column: "id", Desc: true}, {Column: "width"}}, "[files.id desc files.width asc]"},
+	}
+	for _, c := range cases {
+		if got := fmt.Sprint(keyOrders(c.orders)); got != c.expected {
+			t.Errorf("keyOrders(%v) = %v, expected %v", c.orders, got, c.expected)
+		}
+	}
+	cursor := NewCursor([]Order{{Column: "width", Desc: true}}, &File{Model: gorm.Model{ID: 3}, Width: 5})
+	orders, values := cursor.keys()
+	if fmt.Sprint(orders, values) != "[files.width desc files.id desc] [5 3]" {
+		t.Errorf("unexpected keys %v %v", orders, values)
+	}
+}
+
+func TestManageTags(t *testing.T) {
+	setup()
+	first := &File{Fullname: "first.png"}
+	second := &File{Fullname: "second.png"}
+	for _, f := range []*File{first, second} {
+		if err := db.CreateFile(f); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	db.AddTag(first, "colour")
+	db.AddTag(second, "colour")
+	db.AddTag(second, "color")
+	db.AddTag(first, "draft")
+
+	if err := db.RenameTag("colour", "color"); err != ErrTagExisted {
+		t.Errorf("expected %v, got %v", ErrTagExisted, err)
+	}
+	if err := db.MergeTags("colour", "color"); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := db.RenameTag("color", "colors"); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := db.DeleteTag("draft"); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := db.DeleteTag("draft"); err != ErrTagNotFound {
+		t.Errorf("expected %v, got %v", ErrTagNotFound, err)
+	}
+	tags, err := db.GetTags()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if fmt.Sprint(tags) != "[{colors 2}]" {
+		t.Errorf("unexpected tags %v", tags)
+	}
+}
+
+func TestManageTagsWithChildren(t *testing.T) {
+	setup()
+	file := &File{Fullname: "spring.png"}
+	if err := db.CreateFile(file); err != nil {
+		t.Error(err)
+		return
+	}
+	for _, tag := range []string{"campaign/2026", "campaign/2026/spring", "campaign/2026:draft", "campaign/20260"} {
+		db.AddTag(file, tag)
+	}
+	if err := db.DeleteTag("campaign/2026"); err != ErrTagHasChildren {
+		t.Errorf("expected %v, got %v", ErrTagHasChildren, err)
+	}
+	if err := db.MergeTags("campaign/2026", "archive"); err != ErrTagHasChildren {
+		t.Errorf("expected %v, got %v", ErrTagHasChildren, err)
+	}
+	if err := db.RenameTag("campaign/2026", "archive/2026"); err != nil {
+		t.Error(err)
+		return
+	}
+	tags, err := db.GetTags()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	renamed := make(map[string]bool)
+	for _, tag := range tags {
+		renamed[tag.ID] = true
+	}
+	for _, tag := range []string{"archive/2026", "archive/2026/spring", "archive/2026:draft", "campaign/20260"} {
+		if !renamed[tag] {
+			t.Errorf("%s not found in %v", tag, tags)
+		}
+	}
+	if len(tags) != 4 {
+		t.Errorf("unexpected tags %v", tags)
+	}
+	if err := db.DeleteTag("archive/2026/spring"); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestMain(m *testing.M) {
 	code := m.Run()
 	os.Exit(code)
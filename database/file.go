@@ -8,26 +8,17 @@ import (
 
 // region gets
 
-// GetFiles has all of specified tags
-func (db *DB) GetFiles(tags []string, page, size uint, orders []string) ([]File, error) {
+// GetFiles matching the query
+func (db *DB) GetFiles(query *FileQuery) ([]File, error) {
 	var files []File
-	tempDB := db.Model(&File{}).
-		Preload("Tags")
-	if tags != nil && len(tags) > 0 {
-		tempDB = tempDB.
-			Joins("JOIN file_tags ON file_tags.file_id = files.id").
-			Where("file_tags.tag_id in (?)", tags)
-	}
-
-	if orders != nil {
-		for _, od := range orders {
-			tempDB = tempDB.Order(od)
-		}
+	tempDB := query.filter(db.Model(&File{}).
+		Preload("Tags"))
+	tempDB, err := query.order(tempDB)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := tempDB.
-		Offset(size * page).
-		Limit(size).
+	if err := query.paginate(tempDB).
 		Find(&files).
 		Error; err != nil {
 		return nil, err
@@ -35,6 +26,26 @@ func (db *DB) GetFiles(tags []string, page, size uint, orders []string) ([]File,
 	return files, nil
 }
 
+//GetAllFiles which are not deleted
+func (db *DB) GetAllFiles() ([]File, error) {
+	var files []File
+	if err := db.Order("id").Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+//GetFilesUnder a directory, in all its sub directories
+func (db *DB) GetFilesUnder(dir string) ([]File, error) {
+	var files []File
+	if err := db.Where("fullname LIKE ?", escapeLike(dir)+"/%").
+		Order("id").
+		Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
 //GetFileByName return File
 func (db *DB) GetFileByName(filename string) (file *File, err error) {
 	file = &File{Fullname: filename}
@@ -65,13 +76,25 @@ func (db *DB) GetFileByID(id uint) (file *File, err error) {
 	return
 }
 
-//CountFiles has all of specified tags
-func (db *DB) CountFiles(tags []string) (uint, error) {
+//GetFileByIDUnscoped return file even if it was deleted
+func (db *DB) GetFileByIDUnscoped(id uint) (*File, error) {
+	file := &File{}
+	err := db.Unscoped().
+		Where("id = ?", id).
+		First(file).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+//CountFiles matching the query, orders and pagination of the query are ignored
+func (db *DB) CountFiles(query *FileQuery) (uint, error) {
 	var count uint
-	if err := db.Model(&File{}).
-		Preload("Tags").
-		Joins("JOIN file_tags ON file_tags.file_id = files.id").
-		Where("file_tags.tag_id in ?", tags).
+	if err := query.filter(db.Model(&File{})).
 		Count(&count).
 		Error; err != nil {
 		return 0, err
@@ -89,11 +112,13 @@ func (db *DB) CreateFile(file *File) error {
 		Error; err != nil {
 		return err
 	}
-	return db.AddFileHistory(file, CreateAction, file.Fullname)
+	db.fileChanged(file.Fullname)
+	return db.AddFileHistory(file, CreateAction, "")
 }
 
 //RenameFile in database
 func (db *DB) RenameFile(file *File, newName string) error {
+	oldName := file.Fullname
 	file.Fullname = newName
 	file.ExtractParts()
 	if err := db.Model(&File{}).
@@ -101,7 +126,61 @@ func (db *DB) RenameFile(file *File, newName string) error {
 		Error; err != nil {
 		return err
 	}
-	return db.AddFileHistory(file, RenameAction, file.Fullname)
+	db.fileChanged(oldName)
+	db.fileChanged(newName)
+	return db.AddFileHistory(file, RenameAction, "")
+}
+
+//UpdateFileContent save blob and metadata of a file
+func (db *DB) UpdateFileContent(file *File) error {
+	if err := db.Model(file).Updates(map[string]interface{}{
+		"blob_id":      file.BlobID,
+		"size":         file.Size,
+		"content_type": file.ContentType,
+		"width":        file.Width,
+		"height":       file.Height,
+		"color_model":  file.ColorModel,
+		"checksum":     file.Checksum,
+	}).Error; err != nil {
+		return err
+	}
+	db.fileChanged(file.Fullname)
+	return nil
+}
+
+//SetFileFocus saves the focal point of a file, nil x and y clear it
+func (db *DB) SetFileFocus(file *File, x, y *float64) error {
+	file.FocusX, file.FocusY = x, y
+	return db.Model(file).Updates(map[string]interface{}{
+		"focus_x": x,
+		"focus_y": y,
+	}).Error
+}
+
+//ReplaceFile content in database, backup is the path of the old content in history zone
+func (db *DB) ReplaceFile(file *File, backup string) error {
+	if err := db.UpdateFileContent(file); err != nil {
+		return err
+	}
+	return db.AddFileHistory(file, ReplaceAction, backup)
+}
+
+//RestoreFile undeletes a file and saves its restored content,
+//backup is the path of the content before restoring, empty if the file was deleted
+func (db *DB) RestoreFile(file *File, backup string) error {
+	if file.DeletedAt != nil {
+		if err := db.Unscoped().
+			Model(file).
+			Update("deleted_at", nil).
+			Error; err != nil {
+			return err
+		}
+		file.DeletedAt = nil
+	}
+	if err := db.UpdateFileContent(file); err != nil {
+		return err
+	}
+	return db.AddFileHistory(file, RestoreAction, backup)
 }
 
 //DeleteFile in database
@@ -110,6 +189,7 @@ func (db *DB) DeleteFile(file *File, backup string) error {
 		Delete(file).Error; err != nil {
 		return err
 	}
+	db.fileChanged(file.Fullname)
 	return db.AddFileHistory(file, DeleteAction, backup)
 }
 
@@ -117,4 +197,5 @@ func (db *DB) DeleteFile(file *File, backup string) error {
 func (db *DB) UntrackDeleteFile(path string) {
 	name := filepath.Base(path)
 	db.Model(&File{}).Delete(&File{Fullname: name})
+	db.fileChanged(name)
 }
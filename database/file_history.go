@@ -3,16 +3,21 @@ package database
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
 )
 
 // Errors
 var (
-	ErrCreateHistory = errors.New("create-file-history-error")
+	ErrCreateHistory   = errors.New("create-file-history-error")
+	ErrHistoryNotFound = errors.New("file-history-not-found")
 )
 
 //AddFileHistory to db
-func (db *DB) AddFileHistory(file *File, action string, dest string) error {
-	fileHistory := NewFileHistory(file, action, dest)
+func (db *DB) AddFileHistory(file *File, action string, backup string) error {
+	fileHistory := NewFileHistory(file, action, backup)
 	if err := db.Model(&FileHistory{}).
 		Create(&fileHistory).
 		Error; err != nil {
@@ -20,3 +25,117 @@ func (db *DB) AddFileHistory(file *File, action string, dest string) error {
 	}
 	return nil
 }
+
+//GetFileHistories of a file from the oldest, deleted files keep their histories
+func (db *DB) GetFileHistories(fileID uint) ([]FileHistory, error) {
+	var histories []FileHistory
+	if err := db.Where("file_id = ?", fileID).
+		Order("created_at, id").
+		Find(&histories).
+		Error; err != nil {
+		return nil, err
+	}
+	// Every file has at least its created history
+	if len(histories) == 0 {
+		return nil, ErrNotFound
+	}
+	return histories, nil
+}
+
+//GetFileHistory by its id and the file it belongs to
+func (db *DB) GetFileHistory(fileID, id uint) (*FileHistory, error) {
+	var history FileHistory
+	err := db.Where("file_id = ? AND id = ?", fileID, id).
+		First(&history).
+		Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, ErrHistoryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+//GetLastFileHistory of an action on a file
+func (db *DB) GetLastFileHistory(fileID uint, action string) (*FileHistory, error) {
+	var history FileHistory
+	err := db.Where("file_id = ? AND action_type = ?", fileID, action).
+		Order("created_at DESC, id DESC").
+		First(&history).
+		Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, ErrHistoryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+//GetExpiredHistories return histories having a backup which is not one of keepVersions newest backups of its file,
+//or which is created before the given time. Zero values disable the conditions
+func (db *DB) GetExpiredHistories(keepVersions int, before time.Time) ([]FileHistory, error) {
+	var conditions []string
+	var args []interface{}
+	if keepVersions > 0 {
+		conditions = append(conditions, "version > ?")
+		args = append(args, keepVersions)
+	}
+	if !before.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, before)
+	}
+	if len(conditions) == 0 {
+		return nil, nil
+	}
+	var histories []FileHistory
+	if err := db.Raw(`SELECT * FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY file_id ORDER BY created_at DESC, id DESC) AS version
+			FROM file_histories
+			WHERE backup_path <> '' AND purged_at IS NULL AND deleted_at IS NULL
+		) AS backups WHERE `+strings.Join(conditions, " OR ")+" ORDER BY id", args...).
+		Scan(&histories).
+		Error; err != nil {
+		return nil, err
+	}
+	return histories, nil
+}
+
+//GetBackupHistories return histories whose backup is kept
+func (db *DB) GetBackupHistories() ([]FileHistory, error) {
+	var histories []FileHistory
+	if err := db.Where("backup_path <> '' AND purged_at IS NULL").
+		Order("id").
+		Find(&histories).
+		Error; err != nil {
+		return nil, err
+	}
+	return histories, nil
+}
+
+//GetTrashHistories return histories having a backup of deleted files
+func (db *DB) GetTrashHistories() ([]FileHistory, error) {
+	var histories []FileHistory
+	if err := db.Joins("JOIN files ON files.id = file_histories.file_id").
+		Where("files.deleted_at IS NOT NULL").
+		Where("file_histories.backup_path <> '' AND file_histories.purged_at IS NULL").
+		Order("file_histories.id").
+		Find(&histories).
+		Error; err != nil {
+		return nil, err
+	}
+	return histories, nil
+}
+
+//MarkHistoryPurged after its backup is removed
+func (db *DB) MarkHistoryPurged(history *FileHistory) error {
+	now := time.Now()
+	if err := db.Model(history).
+		Update("purged_at", now).
+		Error; err != nil {
+		return err
+	}
+	history.PurgedAt = &now
+	return nil
+}
@@ -2,6 +2,10 @@ package database
 
 //AddTag to a file
 func (db *DB) AddTag(file *File, tagstr string) error {
+	tagstr, err := NormalizeTag(tagstr)
+	if err != nil {
+		return err
+	}
 	tag := Tag{ID: tagstr}
 	db.Create(&tag)
 	if err := db.
@@ -16,6 +20,10 @@ func (db *DB) AddTag(file *File, tagstr string) error {
 
 //RemoveTag from a file
 func (db *DB) RemoveTag(file *File, tag string) error {
+	tag, err := NormalizeTag(tag)
+	if err != nil {
+		return err
+	}
 	if err := db.Model(file).
 		Association("Tags").
 		Delete(&Tag{ID: tag}).
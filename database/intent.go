@@ -0,0 +1,69 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+//CreateIntent before an operation changes files
+func (db *DB) CreateIntent(intent *Intent) error {
+	return db.Create(intent).Error
+}
+
+//SaveIntent after more details of the operation are known
+func (db *DB) SaveIntent(intent *Intent) error {
+	return db.Save(intent).Error
+}
+
+//DeleteIntent after the operation is rolled back
+func (db *DB) DeleteIntent(intent *Intent) error {
+	return db.Delete(intent).Error
+}
+
+//GetIntents of operations which are neither committed nor rolled back, from the oldest
+func (db *DB) GetIntents() ([]Intent, error) {
+	var intents []Intent
+	if err := db.Order("id").Find(&intents).Error; err != nil {
+		return nil, err
+	}
+	return intents, nil
+}
+
+//GetAbandonedIntents of operations of owner, or of any owner if they started before expiry, from the oldest.
+//The operations of other owners are in progress until they expire
+func (db *DB) GetAbandonedIntents(owner string, expiry time.Time) ([]Intent, error) {
+	var intents []Intent
+	if err := db.Where("owner = ? OR created_at < ?", owner, expiry).
+		Order("id").
+		Find(&intents).Error; err != nil {
+		return nil, err
+	}
+	return intents, nil
+}
+
+//CompleteIntent runs the database work of an operation and deletes its intent in one transaction
+func (db *DB) CompleteIntent(intent *Intent, fn func(tx *DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := fn(db.withTx(tx)); err != nil {
+			return err
+		}
+		return tx.Delete(intent).Error
+	})
+}
+
+//Atomic runs fn in one transaction
+func (db *DB) Atomic(fn func(tx *DB) error) error {
+	return db.transaction(func(tx *gorm.DB) error {
+		return fn(db.withTx(tx))
+	})
+}
+
+// transaction runs fn in a new transaction, or in the current one if db is already a transaction
+func (db *DB) transaction(fn func(tx *gorm.DB) error) error {
+	if _, ok := db.CommonDB().(*sql.Tx); ok {
+		return fn(db.DB)
+	}
+	return db.Transaction(fn)
+}
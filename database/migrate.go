@@ -5,7 +5,16 @@ func (db *DB) Migrate() {
 	db.AutoMigrate(&File{})
 	db.AutoMigrate(&Tag{})
 	db.AutoMigrate(&FileHistory{})
+	db.AutoMigrate(&Blob{})
+	db.AutoMigrate(&Intent{})
 	db.Model(&FileHistory{}).AddForeignKey("file_id", "files(id)", "RESTRICT", "RESTRICT")
 	db.Table("file_tags").AddForeignKey("file_id", "files(id)", "RESTRICT", "RESTRICT")
 	db.Table("file_tags").AddForeignKey("tag_id", "tags(id)", "RESTRICT", "RESTRICT")
+	// Histories created before backup path kept the backup path as their name
+	db.Exec(`UPDATE file_histories SET backup_path = file_histories.fullname, fullname = files.fullname
+		FROM files WHERE files.id = file_histories.file_id AND file_histories.backup_path IS NULL
+		AND file_histories.action_type = ?`, DeleteAction)
+	db.Exec("UPDATE file_histories SET backup_path = '' WHERE backup_path IS NULL")
+	// Fill parent of tags created before tag hierarchy
+	db.Exec("UPDATE tags SET parent = COALESCE(substring(id from '^(.*)[/:][^/:]*$'), '') WHERE parent IS NULL")
 }
@@ -4,20 +4,24 @@ import (
 	"errors"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/jinzhu/gorm"
 )
 
 // FileActions
 var (
-	CreateAction = "Created"
-	RenameAction = "Renamed"
-	DeleteAction = "Deleted"
+	CreateAction  = "Created"
+	RenameAction  = "Renamed"
+	DeleteAction  = "Deleted"
+	ReplaceAction = "Replaced"
+	RestoreAction = "Restored"
 )
 
 // Errors
 var (
-	ErrNotFound = errors.New("file-not-found")
+	ErrNotFound       = errors.New("file-not-found")
+	ErrFileNotDeleted = errors.New("file-not-deleted")
 )
 
 // File table
@@ -26,30 +30,84 @@ type File struct {
 	Fullname      string
 	NamePart      string
 	ExtensionPart *string
+	BlobID        *string
+	Size          int64  `gorm:"not null;default:0"`
+	ContentType   string `gorm:"not null;default:''"`
+	Width         int    `gorm:"not null;default:0"`
+	Height        int    `gorm:"not null;default:0"`
+	ColorModel    string `gorm:"not null;default:''"`
+	Checksum      string `gorm:"not null;default:'';index"`
+	FocusX        *float64
+	FocusY        *float64
 	Tags          []Tag `gorm:"many2many:file_tags;association_foreignkey:ID;foreignkey:ID"`
 	FileHistories []FileHistory
 }
 
-// Tag table
+// Tag table, Parent is the tag containing this tag
 type Tag struct {
-	ID string `gorm:"primary_key:true"`
+	ID     string `gorm:"primary_key:true"`
+	Parent string `gorm:"index"`
 }
 
-// FileHistory table store history of file changing
+// BeforeSave extracts parent of tag
+func (t *Tag) BeforeSave() error {
+	t.Parent = ParentTag(t.ID)
+	return nil
+}
+
+// Blob table store content of files by its sha256, shared by files having the same content
+type Blob struct {
+	ID        string `gorm:"primary_key:true"`
+	Size      int64
+	RefCount  int
+	CreatedAt time.Time
+}
+
+// Intent table logs a storage operation before it changes files.
+// The intent is deleted by the transaction committing the operation,
+// so an intent left after a crash of its owner belongs to an operation which must be rolled back.
+// Paths are client paths, TempPath is in working zone and BackupPath is in history zone.
+// BackupPath is set once the operation created its backup, Published once it created its target,
+// Path of a creation or NewPath of a rename, so a rollback removes only what the operation created.
+// Owner is the storage instance running the operation, the database is shared by every instance
+type Intent struct {
+	ID         uint `gorm:"primary_key"`
+	Action     string
+	Path       string
+	NewPath    string
+	TempPath   string
+	BackupPath string
+	BlobID     string
+	Published  bool   `gorm:"not null;default:false"`
+	Owner      string `gorm:"not null;default:'';index"`
+	CreatedAt  time.Time
+}
+
+// FileHistory table store history of file changing.
+// BackupPath is the path in history zone of the content before the action, empty if nothing was backed up.
+// PurgedAt is set when the backup is removed by retention policy
 type FileHistory struct {
 	gorm.Model
 	Fullname      string
 	NamePart      string
 	ExtensionPart *string
 	ActionType    string
+	BackupPath    string
+	PurgedAt      *time.Time
 	FileID        uint
 	File          *File
 }
 
+// HasBackup return true if the content before the action is still kept
+func (f *FileHistory) HasBackup() bool {
+	return f.BackupPath != "" && f.PurgedAt == nil
+}
+
 //NewFileHistory created from File and action
 func NewFileHistory(f *File, action string, backup string) *FileHistory {
 	var h = FileHistory{}
-	h.Fullname = backup
+	h.Fullname = f.Fullname
+	h.BackupPath = backup
 	h.ExtractParts()
 	h.ActionType = action
 	h.FileID = f.ID
@@ -67,6 +125,19 @@ func (f *FileHistory) ExtractParts() {
 	f.NamePart = parts[0]
 }
 
+// SetContent copy content information from another file
+func (f *File) SetContent(other *File) {
+	f.BlobID = other.BlobID
+	f.Size = other.Size
+	f.ContentType = other.ContentType
+	f.Width = other.Width
+	f.Height = other.Height
+	f.ColorModel = other.ColorModel
+	f.Checksum = other.Checksum
+	f.FocusX = other.FocusX
+	f.FocusY = other.FocusY
+}
+
 // ExtractParts from file
 func (f *File) ExtractParts() {
 	ext := filepath.Ext(f.Fullname)
@@ -0,0 +1,185 @@
+package database
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Errors
+var (
+	ErrInvalidOrder = errors.New("order-invalid")
+)
+
+// OrderColumns are columns of files which can be sorted by
+var OrderColumns = []string{
+	"id", "created_at", "updated_at", "fullname",
+	"size", "content_type", "width", "height", "color_model", "checksum",
+}
+
+// Order of files
+type Order struct {
+	Column string `json:"c"`
+	Desc   bool   `json:"d,omitempty"`
+}
+
+// NewOrder from column and direction
+func NewOrder(column, direction string) (Order, error) {
+	order := Order{Column: column}
+	switch strings.ToLower(direction) {
+	case "", "asc":
+	case "desc":
+		order.Desc = true
+	default:
+		return order, ErrInvalidOrder
+	}
+	return order, order.validate()
+}
+
+func (o Order) validate() error {
+	for _, column := range OrderColumns {
+		if column == o.Column {
+			return nil
+		}
+	}
+	return ErrInvalidOrder
+}
+
+func (o Order) String() string {
+	if o.Desc {
+		return "files." + o.Column + " desc"
+	}
+	return "files." + o.Column + " asc"
+}
+
+// FileQuery filters, orders and paginates files.
+// Files are paginated by After instead of Page if it is set.
+type FileQuery struct {
+	AllTags     []string
+	AnyTags     []string
+	NoTags      []string
+	UnderTags   []string
+	Page        uint
+	Size        uint
+	Orders      []Order
+	After       *Cursor
+	ContentType string
+	ColorModel  string
+	Checksum    string
+	MinSize     *int64
+	MaxSize     *int64
+	MinWidth    *int
+	MaxWidth    *int
+	MinHeight   *int
+	MaxHeight   *int
+}
+
+// filter files by conditions of query
+func (q *FileQuery) filter(tx *gorm.DB) *gorm.DB {
+	// tags are matched by sub queries, so files are not duplicated
+	if tags := distinct(q.AllTags); len(tags) > 0 {
+		tx = tx.Where("files.id IN (SELECT file_id FROM file_tags WHERE tag_id IN (?) "+
+			"GROUP BY file_id HAVING COUNT(DISTINCT tag_id) = ?)", tags, len(tags))
+	}
+	if len(q.AnyTags) > 0 {
+		tx = tx.Where("files.id IN (SELECT file_id FROM file_tags WHERE tag_id IN (?))", q.AnyTags)
+	}
+	if len(q.NoTags) > 0 {
+		tx = tx.Where("files.id NOT IN (SELECT file_id FROM file_tags WHERE tag_id IN (?))", q.NoTags)
+	}
+	if len(q.UnderTags) > 0 {
+		var conditions []string
+		var args []interface{}
+		for _, tag := range q.UnderTags {
+			conditions = append(conditions, "tag_id = ?")
+			args = append(args, tag)
+			for _, sep := range TagSeparators {
+				conditions = append(conditions, "tag_id LIKE ?")
+				args = append(args, escapeLike(tag)+string(sep)+"%")
+			}
+		}
+		tx = tx.Where("files.id IN (SELECT file_id FROM file_tags WHERE "+
+			strings.Join(conditions, " OR ")+")", args...)
+	}
+	if q.ContentType != "" {
+		// a type without subtype like "image" matches all of its subtypes
+		if strings.Contains(q.ContentType, "/") {
+			tx = tx.Where("files.content_type = ?", q.ContentType)
+		} else {
+			tx = tx.Where("files.content_type LIKE ?", q.ContentType+"/%")
+		}
+	}
+	if q.ColorModel != "" {
+		tx = tx.Where("files.color_model = ?", q.ColorModel)
+	}
+	if q.Checksum != "" {
+		tx = tx.Where("files.checksum = ?", q.Checksum)
+	}
+	if q.MinSize != nil {
+		tx = tx.Where("files.size >= ?", *q.MinSize)
+	}
+	if q.MaxSize != nil {
+		tx = tx.Where("files.size <= ?", *q.MaxSize)
+	}
+	if q.MinWidth != nil {
+		tx = tx.Where("files.width >= ?", *q.MinWidth)
+	}
+	if q.MaxWidth != nil {
+		tx = tx.Where("files.width <= ?", *q.MaxWidth)
+	}
+	if q.MinHeight != nil {
+		tx = tx.Where("files.height >= ?", *q.MinHeight)
+	}
+	if q.MaxHeight != nil {
+		tx = tx.Where("files.height <= ?", *q.MaxHeight)
+	}
+	return tx
+}
+
+// order files by orders of query, id breaks ties like in the filter of cursors
+func (q *FileQuery) order(tx *gorm.DB) (*gorm.DB, error) {
+	orders := q.Orders
+	if q.After != nil {
+		orders = q.After.Orders
+	}
+	for _, od := range keyOrders(orders) {
+		if err := od.validate(); err != nil {
+			return nil, err
+		}
+		tx = tx.Order(od.String())
+	}
+	return tx, nil
+}
+
+// paginate files by cursor or by page
+func (q *FileQuery) paginate(tx *gorm.DB) *gorm.DB {
+	if q.After != nil {
+		return q.After.filter(tx).Limit(q.Size)
+	}
+	return tx.Offset(q.Size * q.Page).Limit(q.Size)
+}
+
+// NextCursor return cursor pointing after the last file of a result
+func (q *FileQuery) NextCursor(files []File) *Cursor {
+	if len(files) == 0 {
+		return nil
+	}
+	orders := q.Orders
+	if q.After != nil {
+		orders = q.After.Orders
+	}
+	return NewCursor(orders, &files[len(files)-1])
+}
+
+func distinct(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	rs := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			rs = append(rs, v)
+		}
+	}
+	return rs
+}
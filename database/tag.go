@@ -0,0 +1,244 @@
+package database
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Errors
+var (
+	ErrTagNotFound = errors.New("tag-not-found")
+	ErrTagExisted  = errors.New("tag-existed")
+	ErrTagInvalid  = errors.New("tag-invalid")
+	// ErrTagHasChildren is returned when a tag can not be removed while tags are under it
+	ErrTagHasChildren = errors.New("tag-has-children")
+)
+
+// TagSeparators split a tag into a hierarchy,
+// "brand:acme" is under "brand" and "campaign/2026/spring" is under "campaign/2026"
+const TagSeparators = "/:"
+
+// ParentTag return the tag containing tag, empty if tag is at root
+func ParentTag(tag string) string {
+	i := strings.LastIndexAny(tag, TagSeparators)
+	if i < 0 {
+		return ""
+	}
+	return tag[:i]
+}
+
+// TagPath return tags containing tag from the root, the last one is tag itself
+func TagPath(tag string) []string {
+	var path []string
+	for i, r := range tag {
+		if strings.ContainsRune(TagSeparators, r) {
+			path = append(path, tag[:i])
+		}
+	}
+	return append(path, tag)
+}
+
+// NormalizeTag trim spaces around segments of a tag and remove empty segments
+func NormalizeTag(tag string) (string, error) {
+	var sb strings.Builder
+	segment := strings.Builder{}
+	separator := ""
+	flush := func() {
+		if s := strings.TrimSpace(segment.String()); s != "" {
+			if sb.Len() > 0 {
+				sb.WriteString(separator)
+			}
+			sb.WriteString(s)
+		}
+		segment.Reset()
+	}
+	for _, r := range tag {
+		if strings.ContainsRune(TagSeparators, r) {
+			flush()
+			separator = string(r)
+			continue
+		}
+		segment.WriteRune(r)
+	}
+	flush()
+	if sb.Len() == 0 {
+		return "", ErrTagInvalid
+	}
+	return sb.String(), nil
+}
+
+// escapeLike escapes wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// TagUsage is a tag with number of files having it
+type TagUsage struct {
+	ID    string
+	Count uint
+}
+
+//GetTags with number of files having each tag, deleted files are not counted
+func (db *DB) GetTags() ([]TagUsage, error) {
+	var tags []TagUsage
+	if err := db.Table("tags").
+		Select("tags.id, COUNT(files.id) AS count").
+		Joins("LEFT JOIN file_tags ON file_tags.tag_id = tags.id").
+		Joins("LEFT JOIN files ON files.id = file_tags.file_id AND files.deleted_at IS NULL").
+		Group("tags.id").
+		Order("tags.id").
+		Scan(&tags).
+		Error; err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func tagExists(tx *gorm.DB, tag string) (bool, error) {
+	var count int
+	if err := tx.Model(&Tag{}).Where("id = ?", tag).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func requireTag(tx *gorm.DB, tag string) error {
+	existed, err := tagExists(tx, tag)
+	if err != nil {
+		return err
+	}
+	if !existed {
+		return ErrTagNotFound
+	}
+	return nil
+}
+
+// childTags return the tags under tag at any depth, ordered by id
+func childTags(tx *gorm.DB, tag string) ([]string, error) {
+	var conditions []string
+	var args []interface{}
+	for _, sep := range TagSeparators {
+		conditions = append(conditions, "id LIKE ?")
+		args = append(args, escapeLike(tag)+string(sep)+"%")
+	}
+	var children []string
+	if err := tx.Model(&Tag{}).
+		Where(strings.Join(conditions, " OR "), args...).
+		Order("id").
+		Pluck("id", &children).
+		Error; err != nil {
+		return nil, err
+	}
+	return children, nil
+}
+
+// requireLeafTag checks tag exists and no tag is under it
+func requireLeafTag(tx *gorm.DB, tag string) error {
+	if err := requireTag(tx, tag); err != nil {
+		return err
+	}
+	children, err := childTags(tx, tag)
+	if err != nil {
+		return err
+	}
+	if len(children) > 0 {
+		return ErrTagHasChildren
+	}
+	return nil
+}
+
+// moveTag gives newName to the files of tag, newName must not be an existed tag
+func moveTag(tx *gorm.DB, tag, newName string) error {
+	existed, err := tagExists(tx, newName)
+	if err != nil {
+		return err
+	}
+	if existed {
+		return ErrTagExisted
+	}
+	if err := tx.Create(&Tag{ID: newName}).Error; err != nil {
+		return err
+	}
+	if err := tx.Table("file_tags").
+		Where("tag_id = ?", tag).
+		UpdateColumn("tag_id", newName).
+		Error; err != nil {
+		return err
+	}
+	return tx.Delete(&Tag{ID: tag}).Error
+}
+
+//RenameTag of every file, newName must not be an existed tag.
+//Tags under tag are moved under newName
+func (db *DB) RenameTag(tag, newName string) error {
+	newName, err := NormalizeTag(newName)
+	if err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := requireTag(tx, tag); err != nil {
+			return err
+		}
+		children, err := childTags(tx, tag)
+		if err != nil {
+			return err
+		}
+		for _, old := range append([]string{tag}, children...) {
+			if err := moveTag(tx, old, newName+strings.TrimPrefix(old, tag)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+//MergeTags move files of source tag to target tag, then delete source tag.
+//A source having tags under it is not merged
+func (db *DB) MergeTags(source, target string) error {
+	target, err := NormalizeTag(target)
+	if err != nil {
+		return err
+	}
+	if source == target {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := requireLeafTag(tx, source); err != nil {
+			return err
+		}
+		existed, err := tagExists(tx, target)
+		if err != nil {
+			return err
+		}
+		if !existed {
+			if err := tx.Create(&Tag{ID: target}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec("INSERT INTO file_tags (file_id, tag_id) "+
+			"SELECT file_id, ? FROM file_tags WHERE tag_id = ? "+
+			"AND file_id NOT IN (SELECT file_id FROM file_tags WHERE tag_id = ?)",
+			target, source, target).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM file_tags WHERE tag_id = ?", source).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Tag{ID: source}).Error
+	})
+}
+
+//DeleteTag and remove it from every file, a tag having tags under it is not deleted
+func (db *DB) DeleteTag(tag string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := requireLeafTag(tx, tag); err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM file_tags WHERE tag_id = ?", tag).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Tag{ID: tag}).Error
+	})
+}
@@ -1,6 +1,6 @@
 // GENERATED BY THE COMMAND ABOVE; DO NOT EDIT
 // This file was generated by swaggo/swag at
-// 2020-05-21 16:11:27.6606329 +0700 +07 m=+0.042009101
+// 2026-10-18 11:52:11.364231064 +0000 UTC m=+0.074317663
 
 package docs
 
@@ -34,8 +34,61 @@ var doc = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/fsck": {
+            "get": {
+                "description": "Kinds are missing-file, untracked-file, missing-backup, checksum-mismatch and missing-checksum.\nFiles of operations in progress are skipped",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Report inconsistencies between storage and database",
+                "operationId": "CheckStorage",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.FsckProblemRes"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorRes"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Missing files are untracked, untracked files are tracked, missing backups are marked as purged and metadata of mismatched files and files without checksum is read again",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Fix inconsistencies between storage and database",
+                "operationId": "RepairStorage",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.FsckProblemRes"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorRes"
+                        }
+                    }
+                }
+            }
+        },
         "/admin/image": {
             "put": {
+                "description": "The content must be an image of the type told by the extension of name.\nFailures are file-ext-invalid, upload-too-large, file-content-mismatch, image-not-decodable and image-too-many-pixels",
                 "consumes": [
                     "multipart/form-data"
                 ],
@@ -119,6 +172,220 @@ var doc = `{
                 }
             }
         },
+        "/admin/image/{id}/copy": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "summary": "Copy an image to a new name",
+                "operationId": "CopyImage",
+                "parameters": [
+                    {
+                        "description": "copy model",
+                        "name": "model",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ImageCopyReq"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID of image",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ImageInfoRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorRes"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/image/{id}/focus": {
+            "put": {
+                "description": "Crops of fill mode keep the focal point at their center as far as possible",
+                "consumes": [
+                    "application/json"
+                ],
+                "summary": "Set the focal point of an image",
+                "operationId": "SetImageFocus",
+                "parameters": [
+                    {
+                        "description": "focus model",
+                        "name": "model",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ImageFocusReq"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID of image",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ImageInfoRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorRes"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "summary": "Remove the focal point of an image, crops fall back to smart crop",
+                "operationId": "DeleteImageFocus",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of image",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ImageInfoRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorRes"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/image/{id}/history": {
+            "get": {
+                "description": "Replaced and Deleted histories have a backup of the content before the action",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get history of an image from the oldest",
+                "operationId": "GetImageHistory",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of image",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.ImageHistoryRes"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorRes"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/image/{id}/history/{historyId}": {
+            "get": {
+                "summary": "Download the backup content of a history",
+                "operationId": "DownloadImageHistory",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of image",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID of history",
+                        "name": "historyId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {},
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorRes"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/image/{id}/history/{historyId}/restore": {
+            "post": {
+                "description": "The current content is backed up first, a deleted image is undeleted",
+                "summary": "Revert an image to the backup content of a history",
+                "operationId": "RestoreImageHistory",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of image",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID of history",
+                        "name": "historyId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ImageInfoRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorRes"
+                        }
+                    }
+                }
+            }
+        },
         "/admin/image/{id}/rename": {
             "post": {
                 "consumes": [
@@ -233,7 +500,7 @@ var doc = `{
                     },
                     {
                         "type": "string",
-                        "description": "Added tag",
+                        "description": "Removed tag",
                         "name": "tag",
                         "in": "path",
                         "required": true
@@ -250,9 +517,38 @@ var doc = `{
                 }
             }
         },
+        "/admin/image/{id}/undelete": {
+            "post": {
+                "summary": "Undelete an image from the backup of its last deletion",
+                "operationId": "UndeleteImage",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID of image",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ImageInfoRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorRes"
+                        }
+                    }
+                }
+            }
+        },
         "/admin/images": {
             "get": {
-                "description": "Get list of images information",
+                "description": "Get list of images information, paginated by pageCurrent or by cursor.\nnextCursor of a response points to the next page, orders are kept in the cursor.\npageSize is 20 if it is missing, at most 1000.",
                 "produces": [
                     "application/json"
                 ],
@@ -260,13 +556,26 @@ var doc = `{
                 "operationId": "GetImages",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "name": "pageSize",
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "name": "anyTags",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "name": "pageCurrent",
+                        "name": "minSize",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "name": "minWidth",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "name": "maxHeight",
                         "in": "query"
                     },
                     {
@@ -277,6 +586,44 @@ var doc = `{
                         "name": "orderBy",
                         "in": "query"
                     },
+                    {
+                        "type": "string",
+                        "name": "colorModel",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "name": "checksum",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "name": "pageCurrent",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "name": "tags",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "name": "contentType",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "name": "pageSize",
+                        "in": "query"
+                    },
                     {
                         "type": "array",
                         "items": {
@@ -290,17 +637,218 @@ var doc = `{
                         "items": {
                             "type": "string"
                         },
-                        "name": "tags",
+                        "name": "allTags",
                         "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "name": "noTags",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "name": "underTags",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "name": "maxSize",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "name": "maxWidth",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "name": "minHeight",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ImagesRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorRes"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/sign": {
+            "post": {
+                "description": "Signed urls are accepted by the resize and preset routes when signatures are required",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Sign an image url",
+                "operationId": "SignURL",
+                "parameters": [
+                    {
+                        "description": "sign model",
+                        "name": "model",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SignURLReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SignURLRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorRes"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/tags": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get list of tags with number of images having each tag",
+                "operationId": "GetTags",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.TagRes"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorRes"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "A tag having tags under it is not deleted",
+                "summary": "Delete a tag and remove it from every image",
+                "operationId": "DeleteTag",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "name": "tag",
+                        "in": "query",
+                        "required": true
                     }
                 ],
+                "responses": {
+                    "200": {},
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorRes"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/tags/merge": {
+            "post": {
+                "description": "Images having source tag get target tag, then source tag is deleted, a source having tags under it is not merged",
+                "consumes": [
+                    "application/json"
+                ],
+                "summary": "Merge source tag into target tag",
+                "operationId": "MergeTags",
+                "parameters": [
+                    {
+                        "description": "merge model",
+                        "name": "model",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.TagMergeReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {},
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorRes"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/tags/rename": {
+            "post": {
+                "description": "Tags under the tag are renamed with it",
+                "consumes": [
+                    "application/json"
+                ],
+                "summary": "Rename a tag of every image",
+                "operationId": "RenameTag",
+                "parameters": [
+                    {
+                        "description": "rename model",
+                        "name": "model",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.TagRenameReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {},
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorRes"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/tags/tree": {
+            "get": {
+                "description": "Tags are split by \"/\" and \":\", e.g. \"campaign/2026\" is under \"campaign\" and \"brand:acme\" is under \"brand\"",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get tags as a tree",
+                "operationId": "GetTagTree",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.ImageInfoRes"
+                                "$ref": "#/definitions/models.TagNodeRes"
                             }
                         }
                     },
@@ -313,6 +861,74 @@ var doc = `{
                 }
             }
         },
+        "/admin/trash": {
+            "delete": {
+                "description": "Deleted images can not be undeleted after their backups are removed",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Remove every backup of deleted images",
+                "operationId": "EmptyTrash",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.PurgeRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorRes"
+                        }
+                    }
+                }
+            }
+        },
+        "/images/preset/{name}/{/path}": {
+            "get": {
+                "description": "Presets bundle the parameters of GetResizedImage, they are defined by IMAGE_PRESETS",
+                "summary": "Get an image transformed by a named preset",
+                "operationId": "GetPresetImage",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Name of preset, such as thumb, card or hero",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Image local path",
+                        "name": "/path",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Signature made by SignURL, required when the server requires signatures",
+                        "name": "sig",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Expiry of the signature in unix seconds",
+                        "name": "exp",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {},
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorRes"
+                        }
+                    }
+                }
+            }
+        },
         "/images/size/{width}/{height}/{/name}": {
             "get": {
                 "summary": "Get a resized image",
@@ -337,6 +953,78 @@ var doc = `{
                         "name": "/name",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Output format: png, jpeg, gif, or auto to follow the Accept header",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Quality of jpeg output from 1 to 100",
+                        "name": "quality",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Resize mode: exact, fit, fill or pad, exact if empty",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Part kept by fill and pad: center, north, south, east, west, northeast, northwest, southeast or southwest",
+                        "name": "gravity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Horizontal focal point from 0 to 1, used with fy instead of gravity",
+                        "name": "fx",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Vertical focal point from 0 to 1, used with fx instead of gravity",
+                        "name": "fy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Background color of pad mode in hex, white if empty",
+                        "name": "bg",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Convert the image to grayscale",
+                        "name": "grayscale",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Gaussian blur sigma, applied after resizing",
+                        "name": "blur",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Sharpen sigma, applied after resizing",
+                        "name": "sharpen",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Signature made by SignURL, required when the server requires signatures",
+                        "name": "sig",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Expiry of the signature in unix seconds",
+                        "name": "exp",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -360,20 +1048,123 @@ var doc = `{
                 }
             }
         },
+        "models.FsckProblemRes": {
+            "type": "object",
+            "properties": {
+                "fileId": {
+                    "type": "integer"
+                },
+                "fixed": {
+                    "type": "boolean"
+                },
+                "historyId": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ImageCopyReq": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ImageFocusReq": {
+            "type": "object",
+            "required": [
+                "x",
+                "y"
+            ],
+            "properties": {
+                "x": {
+                    "type": "number"
+                },
+                "y": {
+                    "type": "number"
+                }
+            }
+        },
+        "models.ImageFocusRes": {
+            "type": "object",
+            "properties": {
+                "x": {
+                    "type": "number"
+                },
+                "y": {
+                    "type": "number"
+                }
+            }
+        },
+        "models.ImageHistoryRes": {
+            "type": "object",
+            "properties": {
+                "actionType": {
+                    "type": "string"
+                },
+                "backupPath": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "fullname": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "purgedAt": {
+                    "type": "string"
+                }
+            }
+        },
         "models.ImageInfoRes": {
             "type": "object",
             "properties": {
+                "checksum": {
+                    "type": "string"
+                },
+                "colorModel": {
+                    "type": "string"
+                },
+                "contentType": {
+                    "type": "string"
+                },
+                "focus": {
+                    "description": "Focus is the focal point kept by crops, smart crop is used if it is not set",
+                    "type": "object",
+                    "$ref": "#/definitions/models.ImageFocusRes"
+                },
                 "fullname": {
                     "type": "string"
                 },
+                "height": {
+                    "type": "integer"
+                },
                 "id": {
                     "type": "integer"
                 },
+                "size": {
+                    "type": "integer"
+                },
                 "tags": {
                     "type": "array",
                     "items": {
                         "type": "string"
                     }
+                },
+                "width": {
+                    "type": "integer"
                 }
             }
         },
@@ -391,6 +1182,54 @@ var doc = `{
         "models.ImagesReq": {
             "type": "object",
             "properties": {
+                "allTags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "anyTags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "checksum": {
+                    "type": "string"
+                },
+                "colorModel": {
+                    "type": "string"
+                },
+                "contentType": {
+                    "type": "string"
+                },
+                "cursor": {
+                    "type": "string"
+                },
+                "maxHeight": {
+                    "type": "integer"
+                },
+                "maxSize": {
+                    "type": "integer"
+                },
+                "maxWidth": {
+                    "type": "integer"
+                },
+                "minHeight": {
+                    "type": "integer"
+                },
+                "minSize": {
+                    "type": "integer"
+                },
+                "minWidth": {
+                    "type": "integer"
+                },
+                "noTags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
                 "orderBy": {
                     "type": "array",
                     "items": {
@@ -414,6 +1253,144 @@ var doc = `{
                     "items": {
                         "type": "string"
                     }
+                },
+                "underTags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.ImagesRes": {
+            "type": "object",
+            "properties": {
+                "hasNext": {
+                    "type": "boolean"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ImageInfoRes"
+                    }
+                },
+                "nextCursor": {
+                    "type": "string"
+                },
+                "pageCurrent": {
+                    "type": "integer"
+                },
+                "pageSize": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.PurgeRes": {
+            "type": "object",
+            "properties": {
+                "purged": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.SignURLReq": {
+            "type": "object",
+            "required": [
+                "url"
+            ],
+            "properties": {
+                "expiresIn": {
+                    "description": "ExpiresIn is the lifetime of the signature in seconds, zero for a signature which never expires",
+                    "type": "integer"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SignURLRes": {
+            "type": "object",
+            "properties": {
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.TagMergeReq": {
+            "type": "object",
+            "required": [
+                "source",
+                "target"
+            ],
+            "properties": {
+                "source": {
+                    "type": "string"
+                },
+                "target": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.TagNodeRes": {
+            "type": "object",
+            "properties": {
+                "children": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.TagNodeRes"
+                    }
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.TagRenameReq": {
+            "type": "object",
+            "required": [
+                "name",
+                "tag"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "tag": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.TagReq": {
+            "type": "object",
+            "required": [
+                "tag"
+            ],
+            "properties": {
+                "tag": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.TagRes": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
                 }
             }
         }
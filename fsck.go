@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/thanhtuan260593/file-server/database"
+	"github.com/thanhtuan260593/file-server/server"
+	"github.com/thanhtuan260593/file-server/storages"
+)
+
+// runFsck checks the configured storage against database and return the exit code:
+// 0 if nothing is wrong, 1 if problems are left unfixed and 2 if the check failed
+func runFsck(db *database.DB, args []string) int {
+	flags := flag.NewFlagSet("fsck", flag.ExitOnError)
+	fix := flags.Bool("fix", false, "fix found problems")
+	flags.Parse(args)
+
+	storage, err := storages.Open(server.NewConfig().StorageDriver, db)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	problems, err := storages.Fsck(db, storage, *fix)
+	code := 0
+	for _, problem := range problems {
+		status := "found"
+		if problem.Fixed {
+			status = "fixed"
+		} else {
+			code = 1
+		}
+		fmt.Printf("%s\t%s\t%s\n", status, problem.Kind, problem.Path)
+	}
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	return code
+}
@@ -7,6 +7,7 @@ require (
 	github.com/braintree/manners v0.0.0-20160418043613-82a8879fc5fd
 	github.com/cpuguy83/go-md2man/v2 v2.0.0 // indirect
 	github.com/disintegration/imaging v1.6.2
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/gin-contrib/cors v1.3.1
 	github.com/gin-gonic/gin v1.6.3
 	github.com/go-openapi/spec v0.19.8 // indirect
@@ -15,13 +16,16 @@ require (
 	github.com/jinzhu/gorm v1.9.12
 	github.com/lib/pq v1.1.1
 	github.com/mailru/easyjson v0.7.1 // indirect
+	github.com/minio/minio-go/v6 v6.0.57
 	github.com/stretchr/testify v1.4.0
 	github.com/swaggo/gin-swagger v1.2.0
 	github.com/swaggo/swag v1.6.5
 	github.com/twinj/uuid v1.0.0
 	github.com/urfave/cli v1.22.4 // indirect
 	github.com/yusukebe/go-pngquant v0.0.0-20200223090257-49b91f11b627
+	golang.org/x/image v0.0.0-20191009234506-e7c1f5e7dbb8
 	golang.org/x/net v0.0.0-20200520004742-59133d7f0dd7 // indirect
+	golang.org/x/text v0.3.2
 	golang.org/x/tools v0.0.0-20200519205726-57a9e4404bf7 // indirect
 	gopkg.in/yaml.v2 v2.3.0 // indirect
 )
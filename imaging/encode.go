@@ -6,35 +6,33 @@ import (
 	"image/png"
 	"io"
 
-	localstorage "github.com/thanhtuan260593/file-server/storages/local"
 	pngquant "github.com/yusukebe/go-pngquant"
 )
 
-// EncodeImageToReader return reader if no error
-func EncodeImageToReader(img image.Image, ext string) (io.Reader, int64, error) {
-	var resizedBuffer bytes.Buffer
-	var mybytes []byte
-	var err error
-	if err = checkExtension(ext); err != nil {
-		return nil, 0, err
+// EncodeImageToReader return reader of img encoded in format, zero quality is the default quality of format
+func EncodeImageToReader(img image.Image, format *Format, quality int) (io.Reader, int64, error) {
+	if format.encode == nil {
+		return nil, 0, ErrFormatNotSupported
 	}
-	switch ext {
-	case localstorage.PngExt:
-		var encoder = png.Encoder{
-			CompressionLevel: png.BestSpeed,
-		}
-		if err := encoder.Encode(&resizedBuffer, img); err != nil {
-			return nil, 0, err
-		}
-		mybytes = resizedBuffer.Bytes()
-		mybytes, err = pngquant.CompressBytes(mybytes, "1")
-		if err != nil {
-			return nil, 0, err
-		}
-	default:
-		return nil, 0, localstorage.ErrFileExtInvalid
+	var buffer bytes.Buffer
+	if err := format.encode(&buffer, img, quality); err != nil {
+		return nil, 0, err
 	}
+	return bytes.NewReader(buffer.Bytes()), int64(buffer.Len()), nil
+}
 
-	reader := bytes.NewReader(mybytes)
-	return reader, int64(len(mybytes)), nil
+func encodePNG(w io.Writer, img image.Image, quality int) error {
+	var resizedBuffer bytes.Buffer
+	var encoder = png.Encoder{
+		CompressionLevel: png.BestSpeed,
+	}
+	if err := encoder.Encode(&resizedBuffer, img); err != nil {
+		return err
+	}
+	mybytes, err := pngquant.CompressBytes(resizedBuffer.Bytes(), "1")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(mybytes)
+	return err
 }
@@ -0,0 +1,121 @@
+package imaging
+
+import (
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	// register decoders of all supported formats
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+// Format of an encoded image
+type Format struct {
+	Name        string
+	ContentType string
+	Exts        []string
+	// encode is nil for formats which can only be decoded, quality is ignored by lossless formats
+	encode func(w io.Writer, img image.Image, quality int) error
+}
+
+// Supported formats
+var (
+	PNG  = &Format{Name: "png", ContentType: "image/png", Exts: []string{".png"}, encode: encodePNG}
+	JPEG = &Format{Name: "jpeg", ContentType: "image/jpeg", Exts: []string{".jpg", ".jpeg"}, encode: encodeJPEG}
+	GIF  = &Format{Name: "gif", ContentType: "image/gif", Exts: []string{".gif"}, encode: encodeGIF}
+	WEBP = &Format{Name: "webp", ContentType: "image/webp", Exts: []string{".webp"}}
+)
+
+var formats = []*Format{PNG, JPEG, GIF, WEBP}
+
+// FormatByExt return the format of a file extension, extensions are case insensitive
+func FormatByExt(ext string) (*Format, error) {
+	for _, format := range formats {
+		for _, item := range format.Exts {
+			if strings.EqualFold(item, ext) {
+				return format, nil
+			}
+		}
+	}
+	return nil, ErrExtNotSupported
+}
+
+// FormatByName return an encodable format by its name, "jpg" is accepted for jpeg
+func FormatByName(name string) (*Format, error) {
+	name = strings.ToLower(name)
+	if name == "jpg" {
+		name = JPEG.Name
+	}
+	for _, format := range formats {
+		if format.Name == name && format.encode != nil {
+			return format, nil
+		}
+	}
+	return nil, ErrFormatNotSupported
+}
+
+// NegotiateFormat return the encodable format preferred by an Accept header,
+// fallback is returned if the client accepts any image or no encodable format
+func NegotiateFormat(accept string, fallback *Format) *Format {
+	type mediaRange struct {
+		contentType string
+		q           float64
+	}
+	var ranges []mediaRange
+	for _, item := range strings.Split(accept, ",") {
+		contentType, params, err := mime.ParseMediaType(strings.TrimSpace(item))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			q = v
+		}
+		if q > 0 {
+			ranges = append(ranges, mediaRange{contentType, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	for _, r := range ranges {
+		if r.contentType == "*/*" || r.contentType == "image/*" {
+			return fallback
+		}
+		for _, format := range formats {
+			if format.ContentType == r.contentType && format.encode != nil {
+				return format
+			}
+		}
+	}
+	return fallback
+}
+
+// OutputFormat return the format used to encode images decoded from a file extension.
+// Images are encoded in their own format, except formats without encoder which are encoded as png
+func OutputFormat(ext string) (*Format, error) {
+	format, err := FormatByExt(ext)
+	if err != nil {
+		return nil, err
+	}
+	if format.encode == nil {
+		return PNG, nil
+	}
+	return format, nil
+}
+
+func encodeJPEG(w io.Writer, img image.Image, quality int) error {
+	if quality <= 0 {
+		quality = DefaultJpegQuality
+	}
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+}
+
+func encodeGIF(w io.Writer, img image.Image, quality int) error {
+	return gif.Encode(w, img, nil)
+}
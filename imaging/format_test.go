@@ -0,0 +1,79 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatByExt(t *testing.T) {
+	cases := map[string]*Format{
+		".png": PNG, ".PNG": PNG, ".jpg": JPEG, ".JPG": JPEG, ".jpeg": JPEG, ".gif": GIF, ".webp": WEBP,
+	}
+	for ext, expected := range cases {
+		format, err := FormatByExt(ext)
+		if assert.NoError(t, err, ext) {
+			assert.Equal(t, expected, format, ext)
+		}
+	}
+	_, err := FormatByExt(".bmp")
+	assert.Equal(t, ErrExtNotSupported, err)
+
+	// webp can not be encoded, resized webp images are png
+	format, err := OutputFormat(".webp")
+	assert.NoError(t, err)
+	assert.Equal(t, PNG, format)
+	_, _, err = EncodeImageToReader(image.NewGray(image.Rect(0, 0, 1, 1)), WEBP, 0)
+	assert.Equal(t, ErrFormatNotSupported, err)
+}
+
+func TestEncodeImageToReader(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	img.Set(1, 1, color.White)
+	for _, format := range []*Format{JPEG, GIF} {
+		reader, size, err := EncodeImageToReader(img, format, 0)
+		if !assert.NoError(t, err, format.Name) {
+			continue
+		}
+		assert.True(t, size > 0)
+		decoded, name, err := image.Decode(reader)
+		if assert.NoError(t, err, format.Name) {
+			assert.Equal(t, format.Name, name)
+			assert.Equal(t, img.Bounds(), decoded.Bounds())
+		}
+	}
+}
+
+func TestFormatByName(t *testing.T) {
+	for name, expected := range map[string]*Format{"png": PNG, "JPEG": JPEG, "jpg": JPEG, "gif": GIF} {
+		format, err := FormatByName(name)
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, expected, format, name)
+		}
+	}
+	for _, name := range []string{"webp", "bmp", ""} {
+		_, err := FormatByName(name)
+		assert.Equal(t, ErrFormatNotSupported, err, name)
+	}
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	cases := []struct {
+		accept   string
+		expected *Format
+	}{
+		{"", PNG},
+		{"*/*", PNG},
+		{"image/gif", GIF},
+		{"image/webp,image/jpeg;q=0.9,*/*;q=0.8", JPEG},
+		{"image/gif;q=0.5, image/jpeg", JPEG},
+		{"image/*, image/gif", PNG},
+		{"image/jpeg;q=0, image/gif;q=0.1", GIF},
+		{"text/html, invalid;;", PNG},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, NegotiateFormat(c.accept, PNG), c.accept)
+	}
+}
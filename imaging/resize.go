@@ -17,9 +17,12 @@ func Resize(img image.Image, width, height uint) image.Image {
 }
 
 // ResizeAndEncode return reader if no errors
-func ResizeAndEncode(img image.Image, ext string, width, height uint) (io.Reader, int64, error) {
-	resized := Resize(img, width, height)
-	return EncodeImageToReader(resized, ext)
+func ResizeAndEncode(img image.Image, opts Options, format *Format, quality int) (io.Reader, int64, error) {
+	resized, err := Transform(img, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return EncodeImageToReader(resized, format, quality)
 }
 
 func getImageReader(filename string) (io.Reader, uint64, error) {
@@ -0,0 +1,71 @@
+package imaging
+
+import (
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// smartSize bounds the copy of an image analyzed by SmartFocus
+const smartSize = 128
+
+// SmartFocus return the focus of the crop of img with the aspect ratio keeping the most edges.
+// Edges are measured by a Sobel operator on a small grayscale copy of img.
+func SmartFocus(img image.Image, ratio float64) Focus {
+	center := Focus{0.5, 0.5}
+	small := imaging.Grayscale(imaging.Fit(img, smartSize, smartSize, imaging.Box))
+	bounds := small.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w < 3 || h < 3 {
+		return center
+	}
+	crop := cropRect(bounds, ratio, center)
+
+	// energy of each column and each row
+	columns := make([]float64, w)
+	rows := make([]float64, h)
+	lum := func(x, y int) float64 {
+		return float64(small.Pix[y*small.Stride+x*4])
+	}
+	total := 0.0
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			gx := lum(x+1, y-1) + 2*lum(x+1, y) + lum(x+1, y+1) - lum(x-1, y-1) - 2*lum(x-1, y) - lum(x-1, y+1)
+			gy := lum(x-1, y+1) + 2*lum(x, y+1) + lum(x+1, y+1) - lum(x-1, y-1) - 2*lum(x, y-1) - lum(x+1, y-1)
+			e := math.Hypot(gx, gy)
+			columns[x] += e
+			rows[y] += e
+			total += e
+		}
+	}
+	if total == 0 {
+		return center
+	}
+	switch {
+	case crop.Dx() < w:
+		center.X = bestWindow(columns, crop.Dx())
+	case crop.Dy() < h:
+		center.Y = bestWindow(rows, crop.Dy())
+	}
+	return center
+}
+
+// bestWindow return the center, in fraction of len(values), of the window of size n having the largest sum.
+// Among equal windows, the one closest to the middle wins
+func bestWindow(values []float64, n int) float64 {
+	sum := 0.0
+	for _, v := range values[:n] {
+		sum += v
+	}
+	middle := float64(len(values)-n) / 2
+	best, bestSum := 0, sum
+	for start := 1; start+n <= len(values); start++ {
+		sum += values[start+n-1] - values[start-1]
+		// sums are compared with a tolerance, the sliding sum drifts
+		if sum > bestSum+1e-6 || math.Abs(sum-bestSum) <= 1e-6 && math.Abs(float64(start)-middle) < math.Abs(float64(best)-middle) {
+			best, bestSum = start, sum
+		}
+	}
+	return (float64(best) + float64(n)/2) / float64(len(values))
+}
@@ -0,0 +1,48 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// checkered return a flat gray image with a checkerboard in rect
+func checkered(width, height int, rect image.Rectangle) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			c := color.NRGBA{128, 128, 128, 255}
+			if image.Pt(x, y).In(rect) {
+				c = color.NRGBA{255, 255, 255, 255}
+				if (x/4+y/4)%2 == 0 {
+					c = color.NRGBA{0, 0, 0, 255}
+				}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestSmartFocus(t *testing.T) {
+	flat := checkered(200, 100, image.Rectangle{})
+	assert.Equal(t, Focus{0.5, 0.5}, SmartFocus(flat, 1))
+
+	right := checkered(200, 100, image.Rect(150, 20, 190, 80))
+	focus := SmartFocus(right, 1)
+	assert.True(t, focus.X > 0.7, "focus %+v moves to the detail", focus)
+	assert.Equal(t, 0.5, focus.Y)
+
+	top := checkered(100, 300, image.Rect(20, 10, 80, 60))
+	focus = SmartFocus(top, 1)
+	assert.True(t, focus.Y < 0.3, "focus %+v moves to the detail", focus)
+
+}
+
+func TestBestWindow(t *testing.T) {
+	assert.Equal(t, 0.5, bestWindow([]float64{0, 0, 0, 0}, 2))
+	assert.Equal(t, 0.25, bestWindow([]float64{1, 1, 0, 0}, 2))
+	assert.Equal(t, 0.75, bestWindow([]float64{0, 0, 1, 1}, 2))
+}
@@ -0,0 +1,232 @@
+package imaging
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// Resize modes
+const (
+	// ModeExact resizes to the size, the aspect ratio is kept only if width or height is zero
+	ModeExact = "exact"
+	// ModeFit scales the image to fit in the size, keeping its aspect ratio
+	ModeFit = "fit"
+	// ModeFill scales the image to cover the size, then crops it around the focus
+	ModeFill = "fill"
+	// ModePad fits the image in the size, the rest is filled with the background
+	ModePad = "pad"
+)
+
+// Transformation errors
+var (
+	ErrModeInvalid       = errors.New("mode-invalid")
+	ErrGravityInvalid    = errors.New("gravity-invalid")
+	ErrFocusInvalid      = errors.New("focus-invalid")
+	ErrBackgroundInvalid = errors.New("background-invalid")
+	ErrFilterInvalid     = errors.New("filter-invalid")
+)
+
+// MaxSigma bounds the blur and sharpen sigmas, the kernel of a filter grows with its sigma
+var MaxSigma = 100.0
+
+// DefaultBackground fills the padding of pad mode
+var DefaultBackground color.Color = color.White
+
+// Focus is a point of an image in fractions of its width and height, from the top left corner
+type Focus struct {
+	X float64
+	Y float64
+}
+
+// gravities are the focuses of named gravities
+var gravities = map[string]Focus{
+	"center":    {0.5, 0.5},
+	"north":     {0.5, 0},
+	"south":     {0.5, 1},
+	"east":      {1, 0.5},
+	"west":      {0, 0.5},
+	"northeast": {1, 0},
+	"northwest": {0, 0},
+	"southeast": {1, 1},
+	"southwest": {0, 1},
+}
+
+// GravityFocus return the focus of a named gravity
+func GravityFocus(gravity string) (Focus, error) {
+	focus, ok := gravities[strings.ToLower(gravity)]
+	if !ok {
+		return Focus{}, ErrGravityInvalid
+	}
+	return focus, nil
+}
+
+// Options of a transformation
+type Options struct {
+	Width  uint
+	Height uint
+	// Mode is one of ModeExact, ModeFit, ModeFill and ModePad, ModeExact if empty
+	Mode string
+	// Focus is kept in the crop of fill mode and places the image in pad mode.
+	// If nil, fill mode finds the focus by SmartFocus and pad mode centers the image
+	Focus *Focus
+	// Background of pad mode, DefaultBackground if nil
+	Background color.Color
+	// Filters applied after resizing, in this order. Blur and Sharpen are gaussian sigmas, zero to skip
+	Grayscale bool
+	Blur      float64
+	Sharpen   float64
+}
+
+// Validate checks the mode and focus of options
+func (opts Options) Validate() error {
+	switch opts.Mode {
+	case "", ModeExact, ModeFit, ModeFill, ModePad:
+	default:
+		return ErrModeInvalid
+	}
+	if f := opts.Focus; f != nil && (f.X < 0 || f.X > 1 || f.Y < 0 || f.Y > 1 || math.IsNaN(f.X) || math.IsNaN(f.Y)) {
+		return ErrFocusInvalid
+	}
+	if !validSigma(opts.Blur) || !validSigma(opts.Sharpen) {
+		return ErrFilterInvalid
+	}
+	return nil
+}
+
+// String describes options, equal options have the same description
+func (opts Options) String() string {
+	focus := "smart"
+	if opts.Focus != nil {
+		focus = fmt.Sprintf("%g,%g", opts.Focus.X, opts.Focus.Y)
+	}
+	background := DefaultBackground
+	if opts.Background != nil {
+		background = opts.Background
+	}
+	bg := color.NRGBAModel.Convert(background).(color.NRGBA)
+	return fmt.Sprintf("%dx%d mode=%s focus=%s bg=%02x%02x%02x%02x grayscale=%t blur=%g sharpen=%g",
+		opts.Width, opts.Height, opts.Mode, focus, bg.R, bg.G, bg.B, bg.A, opts.Grayscale, opts.Blur, opts.Sharpen)
+}
+
+// validSigma checks a sigma is in 0..MaxSigma
+func validSigma(sigma float64) bool {
+	return sigma >= 0 && sigma <= MaxSigma
+}
+
+// Transform resizes img by options then applies filters
+func Transform(img image.Image, opts Options) (image.Image, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return filter(resize(img, opts), opts), nil
+}
+
+// filter applies the filters of options to img
+func filter(img image.Image, opts Options) image.Image {
+	if opts.Grayscale {
+		img = imaging.Grayscale(img)
+	}
+	if opts.Blur > 0 {
+		img = imaging.Blur(img, opts.Blur)
+	}
+	if opts.Sharpen > 0 {
+		img = imaging.Sharpen(img, opts.Sharpen)
+	}
+	return img
+}
+
+// resize scales img by the size and mode of valid options
+func resize(img image.Image, opts Options) image.Image {
+	width, height := int(opts.Width), int(opts.Height)
+	// without both sides, every mode keeps the aspect ratio
+	if opts.Mode == "" || opts.Mode == ModeExact || width == 0 || height == 0 {
+		return Resize(img, opts.Width, opts.Height)
+	}
+	focus := Focus{0.5, 0.5}
+	if opts.Focus != nil {
+		focus = *opts.Focus
+	}
+	switch opts.Mode {
+	case ModeFill:
+		ratio := float64(width) / float64(height)
+		if opts.Focus == nil {
+			focus = SmartFocus(img, ratio)
+		}
+		crop := cropRect(img.Bounds(), ratio, focus)
+		return imaging.Resize(imaging.Crop(img, crop), width, height, imaging.Lanczos)
+	case ModePad:
+		fitted := fit(img, width, height)
+		background := opts.Background
+		if background == nil {
+			background = DefaultBackground
+		}
+		pos := image.Pt(
+			int(math.Round(focus.X*float64(width-fitted.Bounds().Dx()))),
+			int(math.Round(focus.Y*float64(height-fitted.Bounds().Dy()))))
+		return imaging.Paste(imaging.New(width, height, background), fitted, pos)
+	default:
+		return fit(img, width, height)
+	}
+}
+
+// fit scales img to the largest size in width x height with the same aspect ratio
+func fit(img image.Image, width, height int) image.Image {
+	bounds := img.Bounds()
+	scale := math.Min(float64(width)/float64(bounds.Dx()), float64(height)/float64(bounds.Dy()))
+	w := int(math.Max(1, math.Round(float64(bounds.Dx())*scale)))
+	h := int(math.Max(1, math.Round(float64(bounds.Dy())*scale)))
+	return imaging.Resize(img, w, h, imaging.Lanczos)
+}
+
+// cropRect return the largest rectangle of bounds with the aspect ratio,
+// the focus stays at the center of the rectangle as far as possible
+func cropRect(bounds image.Rectangle, ratio float64, focus Focus) image.Rectangle {
+	w, h := bounds.Dx(), bounds.Dy()
+	if float64(w)/float64(h) > ratio {
+		w = int(math.Max(1, math.Round(float64(h)*ratio)))
+	} else {
+		h = int(math.Max(1, math.Round(float64(w)/ratio)))
+	}
+	x := clamp(int(math.Round(focus.X*float64(bounds.Dx())-float64(w)/2)), 0, bounds.Dx()-w)
+	y := clamp(int(math.Round(focus.Y*float64(bounds.Dy())-float64(h)/2)), 0, bounds.Dy()-h)
+	min := bounds.Min.Add(image.Pt(x, y))
+	return image.Rectangle{min, min.Add(image.Pt(w, h))}
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// ParseColor parses a hex color as rgb, rgba, rrggbb or rrggbbaa, a leading # is allowed
+func ParseColor(s string) (color.Color, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 || len(s) == 4 {
+		var expanded strings.Builder
+		for _, c := range s {
+			expanded.WriteRune(c)
+			expanded.WriteRune(c)
+		}
+		s = expanded.String()
+	}
+	if len(s) == 6 {
+		s += "ff"
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 4 {
+		return nil, ErrBackgroundInvalid
+	}
+	return color.NRGBA{R: b[0], G: b[1], B: b[2], A: b[3]}, nil
+}
@@ -0,0 +1,136 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// halves return an image, red on the left half and blue on the right half
+func halves(width, height int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			if x < width/2 {
+				img.Set(x, y, color.NRGBA{255, 0, 0, 255})
+			} else {
+				img.Set(x, y, color.NRGBA{0, 0, 255, 255})
+			}
+		}
+	}
+	return img
+}
+
+func TestTransformModes(t *testing.T) {
+	src := halves(200, 100)
+	cases := []struct {
+		opts     Options
+		expected image.Point
+	}{
+		{Options{Width: 100, Height: 100}, image.Pt(100, 100)},
+		{Options{Width: 100, Height: 100, Mode: ModeExact}, image.Pt(100, 100)},
+		{Options{Width: 100, Height: 100, Mode: ModeFit}, image.Pt(100, 50)},
+		{Options{Width: 400, Height: 100, Mode: ModeFit}, image.Pt(200, 100)},
+		{Options{Width: 100, Height: 100, Mode: ModeFill}, image.Pt(100, 100)},
+		{Options{Width: 100, Height: 100, Mode: ModePad}, image.Pt(100, 100)},
+		{Options{Width: 100, Mode: ModeFill}, image.Pt(100, 50)},
+	}
+	for _, c := range cases {
+		img, err := Transform(src, c.opts)
+		if assert.NoError(t, err, c.opts.Mode) {
+			assert.Equal(t, c.expected, img.Bounds().Size(), "%+v", c.opts)
+		}
+	}
+
+	_, err := Transform(src, Options{Width: 1, Height: 1, Mode: "stretch"})
+	assert.Equal(t, ErrModeInvalid, err)
+	_, err = Transform(src, Options{Width: 1, Height: 1, Mode: ModeFill, Focus: &Focus{1.5, 0}})
+	assert.Equal(t, ErrFocusInvalid, err)
+}
+
+func TestFillKeepsFocus(t *testing.T) {
+	src := halves(200, 100)
+	west, _ := GravityFocus("west")
+	img, _ := Transform(src, Options{Width: 50, Height: 50, Mode: ModeFill, Focus: &west})
+	r, _, b, _ := img.At(25, 25).RGBA()
+	assert.True(t, r > b, "west keeps the red half")
+
+	img, _ = Transform(src, Options{Width: 50, Height: 50, Mode: ModeFill, Focus: &Focus{0.9, 0.5}})
+	r, _, b, _ = img.At(25, 25).RGBA()
+	assert.True(t, b > r, "a focus on the right keeps the blue half")
+
+	assert.Equal(t, image.Rect(100, 0, 200, 100), cropRect(src.Bounds(), 1, Focus{1, 0.5}))
+	assert.Equal(t, image.Rect(50, 0, 150, 100), cropRect(src.Bounds(), 1, Focus{0.5, 0.5}))
+	assert.Equal(t, image.Rect(0, 0, 200, 50), cropRect(src.Bounds(), 4, Focus{0.5, 0}))
+}
+
+func TestPadFillsBackground(t *testing.T) {
+	background := color.NRGBA{0, 255, 0, 255}
+	img, err := Transform(halves(200, 100), Options{Width: 100, Height: 100, Mode: ModePad, Background: background})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, background, color.NRGBAModel.Convert(img.At(50, 5)))
+	assert.Equal(t, background, color.NRGBAModel.Convert(img.At(50, 95)))
+	r, _, _, _ := img.At(10, 50).RGBA()
+	assert.True(t, r > 0)
+}
+
+func TestTransformFilters(t *testing.T) {
+	src := halves(200, 100)
+	img, err := Transform(src, Options{Width: 100, Height: 50, Grayscale: true})
+	if assert.NoError(t, err) {
+		r, g, b, _ := img.At(10, 10).RGBA()
+		assert.True(t, r == g && g == b, "grayscale")
+	}
+	img, err = Transform(src, Options{Width: 100, Height: 50, Blur: 3})
+	if assert.NoError(t, err) {
+		r, _, b, _ := img.At(50, 25).RGBA()
+		assert.True(t, r > 0 && b > 0, "blur mixes the halves")
+	}
+	_, err = Transform(src, Options{Width: 100, Height: 50, Sharpen: -1})
+	assert.Equal(t, ErrFilterInvalid, err)
+}
+
+func TestFilterSigmaBounds(t *testing.T) {
+	assert.NoError(t, Options{Blur: MaxSigma, Sharpen: MaxSigma}.Validate())
+	for _, sigma := range []float64{MaxSigma + 0.001, 3e7, 1e300, math.Inf(1), math.NaN()} {
+		assert.Equal(t, ErrFilterInvalid, Options{Blur: sigma}.Validate(), "blur %v", sigma)
+		assert.Equal(t, ErrFilterInvalid, Options{Sharpen: sigma}.Validate(), "sharpen %v", sigma)
+		_, err := Transform(halves(20, 10), Options{Width: 10, Height: 5, Blur: sigma})
+		assert.Equal(t, ErrFilterInvalid, err, "blur %v", sigma)
+	}
+}
+
+func TestOptionsString(t *testing.T) {
+	a := Options{Width: 100, Height: 50, Mode: ModeFill, Focus: &Focus{0.2, 0.8}}
+	b := Options{Width: 100, Height: 50, Mode: ModeFill, Focus: &Focus{0.2, 0.8}, Background: color.White}
+	assert.Equal(t, a.String(), b.String())
+	b.Focus = nil
+	assert.NotEqual(t, a.String(), b.String())
+	b.Focus, b.Blur = a.Focus, 1
+	assert.NotEqual(t, a.String(), b.String())
+}
+
+func TestParseColor(t *testing.T) {
+	cases := map[string]color.Color{
+		"fff":      color.NRGBA{255, 255, 255, 255},
+		"#000":     color.NRGBA{0, 0, 0, 255},
+		"ff000080": color.NRGBA{255, 0, 0, 128},
+		"#00ff00":  color.NRGBA{0, 255, 0, 255},
+		"0f08":     color.NRGBA{0, 255, 0, 136},
+	}
+	for s, expected := range cases {
+		c, err := ParseColor(s)
+		if assert.NoError(t, err, s) {
+			assert.Equal(t, expected, c, s)
+		}
+	}
+	for _, s := range []string{"", "ff", "white", "#ggg"} {
+		_, err := ParseColor(s)
+		assert.Equal(t, ErrBackgroundInvalid, err, s)
+	}
+}
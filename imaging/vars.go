@@ -6,17 +6,11 @@ var tempPath = "files/_temp"
 var (
 	//ErrExtNotSupported error
 	ErrExtNotSupported error = errors.New("extension-not-supported")
+	//ErrFormatNotSupported error
+	ErrFormatNotSupported error = errors.New("format-not-supported")
+	//ErrQualityInvalid error
+	ErrQualityInvalid error = errors.New("quality-invalid")
 )
 
-var supportedExts = []string{".png"}
-
-func checkExtension(checkingExt string) (err error) {
-	err = ErrExtNotSupported
-	for _, ext := range supportedExts {
-		if ext == checkingExt {
-			err = nil
-			return
-		}
-	}
-	return
-}
+// DefaultJpegQuality is used to encode jpeg images
+var DefaultJpegQuality = 85
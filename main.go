@@ -6,6 +6,10 @@ import (
 	_ "github.com/lib/pq"
 	"github.com/thanhtuan260593/file-server/database"
 	"github.com/thanhtuan260593/file-server/server"
+
+	// storage drivers
+	_ "github.com/thanhtuan260593/file-server/storages/local"
+	_ "github.com/thanhtuan260593/file-server/storages/s3"
 )
 
 // @title Swagger Example API
@@ -55,6 +59,10 @@ import (
 func main() {
 	dbURL := os.Getenv("DATABASE_URL")
 	db := database.New(dbURL)
+	// file-server fsck [-fix] checks storage against database instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "fsck" {
+		os.Exit(runFsck(db, os.Args[2:]))
+	}
 	server := server.NewServer(db)
 	server.SetupRouter()
 	server.Start()
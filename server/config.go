@@ -1,10 +1,14 @@
 package server
 
 import (
+	"encoding/json"
+	"log"
 	"os"
 	"strconv"
 
+	"github.com/thanhtuan260593/file-server/imaging"
 	"github.com/thanhtuan260593/file-server/server/models"
+	"github.com/thanhtuan260593/file-server/storages"
 )
 
 //MaxBound of image
@@ -13,15 +17,47 @@ var (
 	DefaultMaxHeight uint = 2000
 )
 
+//Default bounds of the cache of derived images
+var (
+	DefaultCacheDir            = "/files/_cache"
+	DefaultCacheMaxBytes int64 = 1 << 30
+)
+
+//DefaultPresets are served at /images/preset/{name}/{path}, IMAGE_PRESETS adds or replaces presets
+var DefaultPresets = map[string]models.Preset{
+	"thumb": {Width: 160, Height: 160, Mode: imaging.ModeFill, Quality: 75},
+	"card":  {Width: 480, Height: 320, Mode: imaging.ModeFill},
+	"hero":  {Width: 1920, Height: 1080, Mode: imaging.ModeFit, Format: models.AutoFormat},
+}
+
 //Config of server
 type Config struct {
-	MaxWidth  uint
-	MaxHeight uint
+	MaxWidth      uint
+	MaxHeight     uint
+	StorageDriver string
+	Retention     storages.RetentionPolicy
+	Upload        storages.UploadPolicy
+	Presets       map[string]models.Preset
+	// SignKey signs image urls, RequireSignature rejects resize and preset requests without a valid signature
+	SignKey          []byte
+	RequireSignature bool
+	// CacheDir keeps derived images up to CacheMaxBytes, zero disables the cache
+	CacheDir      string
+	CacheMaxBytes int64
 }
 
 //NewConfig instance
 func NewConfig() *Config {
-	var config = Config{DefaultMaxWidth, DefaultMaxHeight}
+	var config = Config{
+		MaxWidth:      DefaultMaxWidth,
+		MaxHeight:     DefaultMaxHeight,
+		StorageDriver: storages.DefaultDriver,
+		Retention:     storages.NewRetentionPolicy(),
+		Upload:        storages.NewUploadPolicy(),
+		Presets:       newPresets(os.Getenv("IMAGE_PRESETS")),
+		CacheDir:      DefaultCacheDir,
+		CacheMaxBytes: DefaultCacheMaxBytes,
+	}
 	maxWidth := os.Getenv("IMAGE_MAX_WIDTH")
 	if w, err := strconv.ParseUint(maxWidth, 10, 32); err == nil {
 		config.MaxWidth = uint(w)
@@ -31,9 +67,53 @@ func NewConfig() *Config {
 	if h, err := strconv.ParseUint(maxHeight, 10, 32); err == nil {
 		config.MaxHeight = uint(h)
 	}
+
+	if driver := os.Getenv("STORAGE_DRIVER"); driver != "" {
+		config.StorageDriver = driver
+	}
+
+	if dir := os.Getenv("IMAGE_CACHE_DIR"); dir != "" {
+		config.CacheDir = dir
+	}
+	if v, err := strconv.ParseInt(os.Getenv("IMAGE_CACHE_MAX_BYTES"), 10, 64); err == nil && v >= 0 {
+		config.CacheMaxBytes = v
+	}
+
+	config.SignKey = []byte(os.Getenv("IMAGE_SIGN_KEY"))
+	if v, err := strconv.ParseBool(os.Getenv("IMAGE_REQUIRE_SIGNATURE")); err == nil {
+		config.RequireSignature = v
+	}
+	if config.RequireSignature && len(config.SignKey) == 0 {
+		log.Printf("IMAGE_REQUIRE_SIGNATURE is set without IMAGE_SIGN_KEY, resize and preset requests are rejected")
+	}
 	return &config
 }
 
+//newPresets return DefaultPresets with the presets of a json object by name.
+//Invalid presets are logged and left out
+func newPresets(config string) map[string]models.Preset {
+	presets := make(map[string]models.Preset, len(DefaultPresets))
+	for name, preset := range DefaultPresets {
+		presets[name] = preset
+	}
+	if config == "" {
+		return presets
+	}
+	var custom map[string]models.Preset
+	if err := json.Unmarshal([]byte(config), &custom); err != nil {
+		log.Printf("IMAGE_PRESETS ignored: %v", err)
+		return presets
+	}
+	for name, preset := range custom {
+		if err := preset.Validate(); err != nil {
+			log.Printf("Preset %q ignored: %v", name, err)
+			continue
+		}
+		presets[name] = preset
+	}
+	return presets
+}
+
 //CorrectImageModel image request parameters
 func (conf *Config) CorrectImageModel(img *models.ImageFileReq) {
 	if img.Width > conf.MaxWidth {
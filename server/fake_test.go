@@ -0,0 +1,189 @@
+package server
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/thanhtuan260593/file-server/database"
+	"github.com/thanhtuan260593/file-server/storages"
+)
+
+// fakeBackend keeps files in memory, so handlers are tested without a storage driver.
+// Every change fails with err when it is set
+type fakeBackend struct {
+	mu      sync.Mutex
+	db      *database.DB
+	files   map[string][]byte
+	history map[string][]byte
+	err     error
+}
+
+func newFakeBackend(db *database.DB) *fakeBackend {
+	return &fakeBackend{db: db, files: make(map[string][]byte), history: make(map[string][]byte)}
+}
+
+func (f *fakeBackend) AddFile(reader io.Reader, fileName string) (*database.File, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	if _, ok := f.files[fileName]; ok {
+		return nil, storages.ErrFileExisted
+	}
+	file := &database.File{Fullname: fileName}
+	data, err := f.read(file, reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.db.CreateFile(file); err != nil {
+		return nil, err
+	}
+	f.files[fileName] = data
+	return file, nil
+}
+
+func (f *fakeBackend) ReplaceFile(path string, reader io.Reader) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return "", f.err
+	}
+	file, err := f.db.GetFileByName(path)
+	if err != nil {
+		return "", err
+	}
+	data, err := f.read(file, reader)
+	if err != nil {
+		return "", err
+	}
+	if err := f.db.ReplaceFile(file, path); err != nil {
+		return "", err
+	}
+	f.history[path] = f.files[path]
+	f.files[path] = data
+	return path, nil
+}
+
+func (f *fakeBackend) RenameFile(path, newName string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return "", f.err
+	}
+	if _, ok := f.files[newName]; ok {
+		return "", storages.ErrFileExisted
+	}
+	file, err := f.db.GetFileByName(path)
+	if err != nil {
+		return "", err
+	}
+	if err := f.db.RenameFile(file, newName); err != nil {
+		return "", err
+	}
+	f.files[newName] = f.files[path]
+	delete(f.files, path)
+	return newName, nil
+}
+
+func (f *fakeBackend) CopyFile(path, newName string) (*database.File, error) {
+	f.mu.Lock()
+	data, ok := f.files[path]
+	err := f.err
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, storages.ErrFileNotFound
+	}
+	return f.AddFile(bytes.NewReader(data), newName)
+}
+
+func (f *fakeBackend) DeleteFile(path string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return "", f.err
+	}
+	file, err := f.db.GetFileByName(path)
+	if err != nil {
+		return "", err
+	}
+	if err := f.db.DeleteFile(file, path); err != nil {
+		return "", err
+	}
+	f.history[path] = f.files[path]
+	delete(f.files, path)
+	return path, nil
+}
+
+func (f *fakeBackend) RestoreFile(history *database.FileHistory) (*database.File, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	data, ok := f.history[history.BackupPath]
+	if !ok {
+		return nil, storages.ErrFileNotFound
+	}
+	file, err := f.db.GetFileByIDUnscoped(history.FileID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.read(file, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	if err := f.db.RestoreFile(file, ""); err != nil {
+		return nil, err
+	}
+	f.files[file.Fullname] = data
+	return file, nil
+}
+
+func (f *fakeBackend) GetImage(path string) (image.Image, error) {
+	f.mu.Lock()
+	data, ok := f.files[path]
+	f.mu.Unlock()
+	if !ok {
+		return nil, storages.ErrFileNotFound
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// OpenHistory and Open are not served by the fake
+func (f *fakeBackend) OpenHistory(backupPath string) (http.File, error) {
+	return nil, os.ErrNotExist
+}
+
+func (f *fakeBackend) Open(name string) (http.File, error) {
+	return nil, os.ErrNotExist
+}
+
+func (f *fakeBackend) PurgeHistory(backupPath string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.history, backupPath)
+	return nil
+}
+
+// read reads the content of reader and its metadata into file
+func (f *fakeBackend) read(file *database.File, reader io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := storages.ReadMetadata(file.Fullname, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	meta.Apply(file)
+	return data, nil
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/thanhtuan260593/file-server/server/models"
+	"github.com/thanhtuan260593/file-server/storages"
+)
+
+// HandleCheckStorage godocs
+// @Id CheckStorage
+// @Summary Report inconsistencies between storage and database
+// @Description Kinds are missing-file, untracked-file, missing-backup, checksum-mismatch and missing-checksum.
+// @Description Files of operations in progress are skipped
+// @Produce  json
+// @Success 200 {array} models.FsckProblemRes
+// @Failure 400 {object} models.ErrorRes
+// @Router /admin/fsck [get]
+func (s *Server) HandleCheckStorage(c *gin.Context) {
+	s.checkStorage(c, false)
+}
+
+// HandleRepairStorage godocs
+// @Id RepairStorage
+// @Summary Fix inconsistencies between storage and database
+// @Description Missing files are untracked, untracked files are tracked, missing backups are marked as purged and metadata of mismatched files and files without checksum is read again
+// @Produce  json
+// @Success 200 {array} models.FsckProblemRes
+// @Failure 400 {object} models.ErrorRes
+// @Router /admin/fsck [post]
+func (s *Server) HandleRepairStorage(c *gin.Context) {
+	s.checkStorage(c, true)
+}
+
+func (s *Server) checkStorage(c *gin.Context, fix bool) {
+	problems, err := storages.Fsck(s.db, s.storage, fix)
+	if err != nil {
+		errorJSON(c, err)
+		return
+	}
+	c.JSON(200, models.NewFsckRes(problems))
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"mime"
+	"net/http"
+	"path"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thanhtuan260593/file-server/database"
+	"github.com/thanhtuan260593/file-server/server/models"
+	"github.com/thanhtuan260593/file-server/storages"
+)
+
+// HandleGetImageHistory godocs
+// @Id GetImageHistory
+// @Summary Get history of an image from the oldest
+// @Description Replaced and Deleted histories have a backup of the content before the action
+// @Produce  json
+// @Param id path uint true "ID of image"
+// @Success 200 {array} models.ImageHistoryRes
+// @Failure 400 {object} models.ErrorRes
+// @Router /admin/image/{id}/history [get]
+func (s *Server) HandleGetImageHistory(c *gin.Context) {
+	var model models.ImageIDReq
+	if err := errorJSON(c, c.BindUri(&model)); err != nil {
+		return
+	}
+	histories, err := s.db.GetFileHistories(model.ID)
+	if err != nil {
+		errorJSON(c, err)
+		return
+	}
+	rs := make([]*models.ImageHistoryRes, len(histories))
+	for i := range histories {
+		rs[i] = models.NewImageHistoryRes(&histories[i])
+	}
+	c.JSON(200, rs)
+}
+
+// HandleDownloadImageHistory godocs
+// @Id DownloadImageHistory
+// @Summary Download the backup content of a history
+// @Param id path uint true "ID of image"
+// @Param historyId path uint true "ID of history"
+// @Success 200
+// @Failure 400 {object} models.ErrorRes
+// @Router /admin/image/{id}/history/{historyId} [get]
+func (s *Server) HandleDownloadImageHistory(c *gin.Context) {
+	var model models.ImageHistoryReq
+	if err := errorJSON(c, c.BindUri(&model)); err != nil {
+		return
+	}
+	history, err := s.db.GetFileHistory(model.ID, model.HistoryID)
+	if err != nil {
+		errorJSON(c, err)
+		return
+	}
+	if !history.HasBackup() {
+		errorJSON(c, storages.ErrFileNotFound)
+		return
+	}
+	f, err := s.storage.OpenHistory(history.BackupPath)
+	if err != nil {
+		errorJSON(c, storages.ErrFileNotFound)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		errorJSON(c, err)
+		return
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": path.Base(history.Fullname),
+	}))
+	http.ServeContent(c.Writer, c.Request, history.Fullname, info.ModTime(), f)
+}
+
+// HandleUndeleteImage godocs
+// @Id UndeleteImage
+// @Summary Undelete an image from the backup of its last deletion
+// @Param id path uint true "ID of image"
+// @Success 200 {object} models.ImageInfoRes
+// @Failure 400 {object} models.ErrorRes
+// @Router /admin/image/{id}/undelete [post]
+func (s *Server) HandleUndeleteImage(c *gin.Context) {
+	var model models.ImageIDReq
+	if err := errorJSON(c, c.BindUri(&model)); err != nil {
+		return
+	}
+	file, err := s.db.GetFileByIDUnscoped(model.ID)
+	if err != nil {
+		errorJSON(c, err)
+		return
+	}
+	if file.DeletedAt == nil {
+		errorJSON(c, database.ErrFileNotDeleted)
+		return
+	}
+	history, err := s.db.GetLastFileHistory(model.ID, database.DeleteAction)
+	if err != nil {
+		errorJSON(c, err)
+		return
+	}
+	s.restoreImage(c, history)
+}
+
+// HandleRestoreImageHistory godocs
+// @Id RestoreImageHistory
+// @Summary Revert an image to the backup content of a history
+// @Description The current content is backed up first, a deleted image is undeleted
+// @Param id path uint true "ID of image"
+// @Param historyId path uint true "ID of history"
+// @Success 200 {object} models.ImageInfoRes
+// @Failure 400 {object} models.ErrorRes
+// @Router /admin/image/{id}/history/{historyId}/restore [post]
+func (s *Server) HandleRestoreImageHistory(c *gin.Context) {
+	var model models.ImageHistoryReq
+	if err := errorJSON(c, c.BindUri(&model)); err != nil {
+		return
+	}
+	history, err := s.db.GetFileHistory(model.ID, model.HistoryID)
+	if err != nil {
+		errorJSON(c, err)
+		return
+	}
+	s.restoreImage(c, history)
+}
+
+func (s *Server) restoreImage(c *gin.Context, history *database.FileHistory) {
+	file, err := s.storage.RestoreFile(history)
+	if err != nil {
+		errorJSON(c, err)
+		return
+	}
+	c.JSON(200, models.NewImageInfoRes(file))
+}
+
+// HandleEmptyTrash godocs
+// @Id EmptyTrash
+// @Summary Remove every backup of deleted images
+// @Description Deleted images can not be undeleted after their backups are removed
+// @Produce  json
+// @Success 200 {object} models.PurgeRes
+// @Failure 400 {object} models.ErrorRes
+// @Router /admin/trash [delete]
+func (s *Server) HandleEmptyTrash(c *gin.Context) {
+	purged, err := storages.EmptyTrash(s.db, s.storage)
+	if err != nil {
+		errorJSON(c, err)
+		return
+	}
+	c.JSON(200, models.PurgeRes{Purged: purged})
+}
@@ -1,18 +1,23 @@
 package server
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"log"
 	"path/filepath"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/thanhtuan260593/file-server/imaging"
 	"github.com/thanhtuan260593/file-server/server/models"
+	"github.com/thanhtuan260593/file-server/storages"
 )
 
 // HandleUploadImage godocs
 // @Id UploadImage
 // @Summary Upload an image
+// @Description The content must be an image of the type told by the extension of name.
+// @Description Failures are file-ext-invalid, upload-too-large, file-content-mismatch, image-not-decodable and image-too-many-pixels
 // @Accept multipart/form-data
 // @Param file formData file true "Upload file"
 // @Param name formData string true "File name"
@@ -21,15 +26,19 @@ import (
 // @Router /admin/image [put]
 func (s *Server) HandleUploadImage(c *gin.Context) {
 	var model models.ImageNewReq
+	if err := errorJSON(c, s.limitUpload(c)); err != nil {
+		return
+	}
 	if err := c.Bind(&model); err != nil {
 		return
 	}
 	// single file
-	reader, err := getFileFromGinContext(c)
+	reader, err := s.getFileFromGinContext(c, model.Name)
 	if err != nil {
 		errorJSON(c, err)
 		return
 	}
+	defer reader.Close()
 	file, err := s.storage.AddFile(reader, model.Name)
 	if err != nil {
 		errorJSON(c, err)
@@ -45,6 +54,18 @@ func (s *Server) HandleUploadImage(c *gin.Context) {
 // @Param width path uint true "Width of image. Zero if resize scaled on its height"
 // @Param height path uint true "Height of image. Zero if resize scaled on its width"
 // @Param /name path string true "Image local path"
+// @Param format query string false "Output format: png, jpeg, gif, or auto to follow the Accept header"
+// @Param quality query int false "Quality of jpeg output from 1 to 100"
+// @Param mode query string false "Resize mode: exact, fit, fill or pad, exact if empty"
+// @Param gravity query string false "Part kept by fill and pad: center, north, south, east, west, northeast, northwest, southeast or southwest"
+// @Param fx query number false "Horizontal focal point from 0 to 1, used with fy instead of gravity"
+// @Param fy query number false "Vertical focal point from 0 to 1, used with fx instead of gravity"
+// @Param bg query string false "Background color of pad mode in hex, white if empty"
+// @Param grayscale query bool false "Convert the image to grayscale"
+// @Param blur query number false "Gaussian blur sigma, applied after resizing"
+// @Param sharpen query number false "Sharpen sigma, applied after resizing"
+// @Param sig query string false "Signature made by SignURL, required when the server requires signatures"
+// @Param exp query int false "Expiry of the signature in unix seconds"
 // @Success 200
 // @Failure 400 {object} models.ErrorRes
 // @Router /images/size/{width}/{height}/{/name} [get]
@@ -53,25 +74,121 @@ func (s *Server) HandleResize(c *gin.Context) {
 	if err := errorJSON(c, c.BindUri(&model)); err != nil {
 		return
 	}
+	if err := errorJSON(c, c.BindQuery(&model)); err != nil {
+		return
+	}
 	s.config.CorrectImageModel(&model)
-	img, err := s.storage.GetImage(model.FileName)
+	s.serveImage(c, &model)
+}
+
+// HandlePreset godocs
+// @Id GetPresetImage
+// @Summary Get an image transformed by a named preset
+// @Description Presets bundle the parameters of GetResizedImage, they are defined by IMAGE_PRESETS
+// @Param name path string true "Name of preset, such as thumb, card or hero"
+// @Param /path path string true "Image local path"
+// @Param sig query string false "Signature made by SignURL, required when the server requires signatures"
+// @Param exp query int false "Expiry of the signature in unix seconds"
+// @Success 200
+// @Failure 400 {object} models.ErrorRes
+// @Router /images/preset/{name}/{/path} [get]
+func (s *Server) HandlePreset(c *gin.Context) {
+	var model models.ImagePresetReq
+	if err := errorJSON(c, c.BindUri(&model)); err != nil {
+		return
+	}
+	preset, ok := s.config.Presets[model.Name]
+	if !ok {
+		errorJSON(c, models.ErrPresetNotFound)
+		return
+	}
+	req := preset.Request(model.FileName)
+	s.config.CorrectImageModel(&req)
+	s.serveImage(c, &req)
+}
+
+// serveImage writes the image of a resize request
+func (s *Server) serveImage(c *gin.Context, model *models.ImageFileReq) {
+	clientPath, err := storages.CleanPath(model.FileName)
 	if err != nil {
 		errorJSON(c, err)
 		return
 	}
-	var ext = filepath.Ext(model.FileName)
-	//resReader, contentLength, err := imaging.ResizeAndEncode(img, ext, model.Width, model.Height)
-	resReader, contentLength, err := imaging.ResizeAndEncode(img, ext, model.Width, model.Height)
+	opts, err := model.Options()
 	if err != nil {
 		errorJSON(c, err)
 		return
 	}
-
-	contentType := "image/" + strings.Trim(ext, ".")
+	// files which are not tracked are served without focal point and cache
+	file, err := s.db.GetFileByName(clientPath)
+	if err != nil {
+		file = nil
+	}
+	if file != nil && opts.Mode == imaging.ModeFill && opts.Focus == nil && file.FocusX != nil && file.FocusY != nil {
+		// the focal point of editors wins over smart crop
+		opts.Focus = &imaging.Focus{X: *file.FocusX, Y: *file.FocusY}
+	}
+	format, err := imaging.OutputFormat(filepath.Ext(model.FileName))
+	if err != nil {
+		errorJSON(c, err)
+		return
+	}
+	switch model.Format {
+	case "":
+	case models.AutoFormat:
+		format = imaging.NegotiateFormat(c.GetHeader("Accept"), format)
+		c.Header("Vary", "Accept")
+	default:
+		if format, err = imaging.FormatByName(model.Format); err != nil {
+			errorJSON(c, err)
+			return
+		}
+	}
 	extraHeaders := map[string]string{
 		"Content-Disposition": `inline`,
 	}
-	c.DataFromReader(200, int64(contentLength), contentType, resReader, extraHeaders)
+
+	// derived images are cached by the content of their source, a changed source never hits an old entry
+	var cacheKey string
+	if s.cache != nil && file != nil && file.Checksum != "" {
+		cacheKey = fmt.Sprintf("%s %v format=%s quality=%d", file.Checksum, opts, format.Name, model.Quality)
+		if data, ok := s.cache.Get(clientPath, cacheKey); ok {
+			c.DataFromReader(200, int64(len(data)), format.ContentType, bytes.NewReader(data), extraHeaders)
+			return
+		}
+	}
+	img, err := s.storage.GetImage(model.FileName)
+	if err != nil {
+		errorJSON(c, err)
+		return
+	}
+	resReader, contentLength, err := imaging.ResizeAndEncode(img, opts, format, model.Quality)
+	if err != nil {
+		errorJSON(c, err)
+		return
+	}
+	if cacheKey != "" {
+		data, err := ioutil.ReadAll(resReader)
+		if err != nil {
+			errorJSON(c, err)
+			return
+		}
+		if err := s.cache.Put(clientPath, cacheKey, data); err != nil {
+			log.Printf("Derived image of %s not cached: %v", clientPath, err)
+		}
+		resReader = bytes.NewReader(data)
+	}
+	c.DataFromReader(200, int64(contentLength), format.ContentType, resReader, extraHeaders)
+}
+
+// invalidateCache drops the derived images of a source which changed, it is called by the database
+func (s *Server) invalidateCache(path string) {
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.Invalidate(path); err != nil {
+		log.Printf("Derived images of %s not invalidated: %v", path, err)
+	}
 }
 
 // HandleDeleteImage godocs
@@ -89,6 +206,7 @@ func (s *Server) HandleDeleteImage(c *gin.Context) {
 	file, err := s.db.GetFileByID(model.ID)
 	if err != nil {
 		errorJSON(c, err)
+		return
 	}
 	if _, err := s.storage.DeleteFile(file.Fullname); err != nil {
 		errorJSON(c, err)
@@ -127,6 +245,90 @@ func (s *Server) HandleRenameImage(c *gin.Context) {
 	c.Status(200)
 }
 
+// HandleCopyImage godocs
+// @Id CopyImage
+// @Summary Copy an image to a new name
+// @Accept application/json
+// @Param model body models.ImageCopyReq true "copy model"
+// @Param id path uint true "ID of image"
+// @Success 200 {object} models.ImageInfoRes
+// @Failure 400 {object} models.ErrorRes
+// @Router /admin/image/{id}/copy [post]
+func (s *Server) HandleCopyImage(c *gin.Context) {
+	var model models.ImageCopyReq
+	var modelID models.ImageIDReq
+	if err := errorJSON(c, c.BindJSON(&model)); err != nil {
+		return
+	}
+	if err := errorJSON(c, c.BindUri(&modelID)); err != nil {
+		return
+	}
+	file, err := s.db.GetFileByID(modelID.ID)
+	if err != nil {
+		errorJSON(c, err)
+		return
+	}
+	copied, err := s.storage.CopyFile(file.Fullname, model.Name)
+	if err != nil {
+		errorJSON(c, err)
+		return
+	}
+	c.JSON(200, models.NewImageInfoRes(copied))
+}
+
+// HandleSetImageFocus godocs
+// @Id SetImageFocus
+// @Summary Set the focal point of an image
+// @Description Crops of fill mode keep the focal point at their center as far as possible
+// @Accept application/json
+// @Param model body models.ImageFocusReq true "focus model"
+// @Param id path uint true "ID of image"
+// @Success 200 {object} models.ImageInfoRes
+// @Failure 400 {object} models.ErrorRes
+// @Router /admin/image/{id}/focus [put]
+func (s *Server) HandleSetImageFocus(c *gin.Context) {
+	var model models.ImageFocusReq
+	var modelID models.ImageIDReq
+	if err := errorJSON(c, c.BindJSON(&model)); err != nil {
+		return
+	}
+	if err := errorJSON(c, c.BindUri(&modelID)); err != nil {
+		return
+	}
+	file, err := s.db.GetFileByID(modelID.ID)
+	if err != nil {
+		errorJSON(c, err)
+		return
+	}
+	if err := errorJSON(c, s.db.SetFileFocus(file, model.X, model.Y)); err != nil {
+		return
+	}
+	c.JSON(200, models.NewImageInfoRes(file))
+}
+
+// HandleDeleteImageFocus godocs
+// @Id DeleteImageFocus
+// @Summary Remove the focal point of an image, crops fall back to smart crop
+// @Param id path uint true "ID of image"
+// @Success 200 {object} models.ImageInfoRes
+// @Failure 400 {object} models.ErrorRes
+// @Router /admin/image/{id}/focus [delete]
+func (s *Server) HandleDeleteImageFocus(c *gin.Context) {
+	var modelID models.ImageIDReq
+	if err := errorJSON(c, c.BindUri(&modelID)); err != nil {
+		return
+	}
+	file, err := s.db.GetFileByID(modelID.ID)
+	if err != nil {
+		errorJSON(c, err)
+		return
+	}
+	if err := errorJSON(c, s.db.SetFileFocus(file, nil, nil)); err != nil {
+		return
+	}
+	c.JSON(200, models.NewImageInfoRes(file))
+}
+
 // HandleReplaceImage godoc
 // @Id ReplaceImage
 // @Summary Replace an image
@@ -143,6 +345,9 @@ func (s *Server) HandleReplaceImage(c *gin.Context) {
 	if err := errorJSON(c, c.BindUri(&model)); err != nil {
 		return
 	}
+	if err := errorJSON(c, s.limitUpload(c)); err != nil {
+		return
+	}
 
 	file, err := s.db.GetFileByID(model.ID)
 	if err != nil {
@@ -150,11 +355,13 @@ func (s *Server) HandleReplaceImage(c *gin.Context) {
 		return
 	}
 
-	reader, err := getFileFromGinContext(c)
+	// the new content must have the type of the file
+	reader, err := s.getFileFromGinContext(c, file.Fullname)
 	if err != nil {
 		errorJSON(c, err)
 		return
 	}
+	defer reader.Close()
 	if _, err := s.storage.ReplaceFile(file.Fullname, reader); err != nil {
 		errorJSON(c, err)
 		return
@@ -166,10 +373,12 @@ func (s *Server) HandleReplaceImage(c *gin.Context) {
 // HandleGetImages godocs
 // @Id GetImages
 // @Summary Get list of images information
-// @Description Get list of images information
+// @Description Get list of images information, paginated by pageCurrent or by cursor.
+// @Description nextCursor of a response points to the next page, orders are kept in the cursor.
+// @Description pageSize is 20 if it is missing, at most 1000.
 // @Produce  json
 // @Param model query models.ImagesReq false "query model"
-// @Success 200 {array} models.ImageInfoRes
+// @Success 200 {object} models.ImagesRes
 // @Failure 400 {object} models.ErrorRes
 // @Router /admin/images [get]
 func (s *Server) HandleGetImages(c *gin.Context) {
@@ -177,27 +386,32 @@ func (s *Server) HandleGetImages(c *gin.Context) {
 	if err := errorJSON(c, c.BindQuery(&model)); err != nil {
 		return
 	}
-	var orders []string
-	if model.OrderBy != nil {
-		orders = make([]string, len(model.OrderBy))
-		for i, by := range model.OrderBy {
-			dir := "asc"
-			if model.OrderDir != nil && len(model.OrderDir) > i {
-				dir = model.OrderDir[i]
-			}
-			orders[i] = fmt.Sprintf("%v %v", by, dir)
-		}
+	query, err := model.Query()
+	if err != nil {
+		errorJSON(c, err)
+		return
 	}
 
-	imgs, err := s.db.GetFiles(model.Tags, model.PageCurrent, model.PageSize, orders)
+	// Fetch one more image to know if there is a next page
+	fetchQuery := *query
+	fetchQuery.Size++
+	imgs, err := s.db.GetFiles(&fetchQuery)
 	if err != nil {
 		errorJSON(c, err)
 		return
 	}
-	var rs []*models.ImageInfoRes
-	rs = make([]*models.ImageInfoRes, len(imgs))
-	for i, img := range imgs {
-		rs[i] = models.NewImageInfoRes(&img)
+	hasNext := uint(len(imgs)) > query.Size
+	if hasNext {
+		imgs = imgs[:query.Size]
+	}
+	total, err := s.db.CountFiles(query)
+	if err != nil {
+		errorJSON(c, err)
+		return
+	}
+	rs := models.NewImagesRes(imgs, total, query.Size, model.PageCurrent, hasNext)
+	if hasNext && len(imgs) > 0 {
+		rs.NextCursor = query.NextCursor(imgs).Encode()
 	}
 	c.JSON(200, rs)
 }
@@ -240,7 +454,7 @@ func (s *Server) HandleAddImageTag(c *gin.Context) {
 		errorJSON(c, err)
 		return
 	}
-	if err := s.db.AddTag(file, model.Tag); err != nil {
+	if err := s.db.AddTag(file, model.TagName()); err != nil {
 		errorJSON(c, err)
 		return
 	}
@@ -251,7 +465,7 @@ func (s *Server) HandleAddImageTag(c *gin.Context) {
 // @Id RemoveImageTag
 // @Summary Remove a tag from an image
 // @Param id path uint true "ID of image"
-// @Param tag path string true "Added tag"
+// @Param tag path string true "Removed tag"
 // @Success 200
 // @Failure 400 {object} models.ErrorRes
 // @Router /admin/image/{id}/tag/{tag} [delete]
@@ -265,7 +479,7 @@ func (s *Server) HandleRemoveImageTag(c *gin.Context) {
 		errorJSON(c, err)
 		return
 	}
-	if err := s.db.RemoveTag(file, model.Tag); err != nil {
+	if err := s.db.RemoveTag(file, model.TagName()); err != nil {
 		errorJSON(c, err)
 		return
 	}
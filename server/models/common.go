@@ -8,7 +8,7 @@ type Pagination struct {
 
 // ImageOrder model
 type ImageOrder struct {
-	By        uint `json:"by" validate:"oneof=id created_at fullname"`
+	By        uint `json:"by" validate:"oneof=id created_at updated_at fullname size content_type width height color_model checksum"`
 	Direction uint `json:"direction" validate:"oneof=asc desc"`
 }
 
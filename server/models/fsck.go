@@ -0,0 +1,27 @@
+package models
+
+import "github.com/thanhtuan260593/file-server/storages"
+
+//FsckProblemRes model
+type FsckProblemRes struct {
+	Kind      string `json:"kind"`
+	Path      string `json:"path"`
+	FileID    uint   `json:"fileId,omitempty"`
+	HistoryID uint   `json:"historyId,omitempty"`
+	Fixed     bool   `json:"fixed"`
+}
+
+//NewFsckRes model
+func NewFsckRes(problems []storages.Problem) []FsckProblemRes {
+	rs := make([]FsckProblemRes, len(problems))
+	for i, problem := range problems {
+		rs[i] = FsckProblemRes{
+			Kind:      problem.Kind,
+			Path:      problem.Path,
+			FileID:    problem.FileID,
+			HistoryID: problem.HistoryID,
+			Fixed:     problem.Fixed,
+		}
+	}
+	return rs
+}
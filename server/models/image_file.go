@@ -1,8 +1,59 @@
 package models
 
+import "github.com/thanhtuan260593/file-server/imaging"
+
+//AutoFormat picks the output format by the Accept header
+const AutoFormat = "auto"
+
 //ImageFileReq model
 type ImageFileReq struct {
 	Width    uint   `uri:"width"`
 	Height   uint   `uri:"height"`
 	FileName string `uri:"name" binding:"required"`
+	// Format of output image, the format of the source image if empty
+	Format  string `form:"format"`
+	Quality int    `form:"quality" binding:"min=0,max=100"`
+	Mode    string `form:"mode"`
+	// Gravity names the part kept by fill mode, FocusX and FocusY give the point instead
+	Gravity    string   `form:"gravity"`
+	FocusX     *float64 `form:"fx"`
+	FocusY     *float64 `form:"fy"`
+	Background string   `form:"bg"`
+	// Filters applied after resizing
+	Grayscale bool    `form:"grayscale"`
+	Blur      float64 `form:"blur"`
+	Sharpen   float64 `form:"sharpen"`
+}
+
+//Options of the transformation asked by the request
+func (req *ImageFileReq) Options() (imaging.Options, error) {
+	opts := imaging.Options{
+		Width:     req.Width,
+		Height:    req.Height,
+		Mode:      req.Mode,
+		Grayscale: req.Grayscale,
+		Blur:      req.Blur,
+		Sharpen:   req.Sharpen,
+	}
+	switch {
+	case req.FocusX != nil || req.FocusY != nil:
+		if req.FocusX == nil || req.FocusY == nil || req.Gravity != "" {
+			return opts, imaging.ErrFocusInvalid
+		}
+		opts.Focus = &imaging.Focus{X: *req.FocusX, Y: *req.FocusY}
+	case req.Gravity != "":
+		focus, err := imaging.GravityFocus(req.Gravity)
+		if err != nil {
+			return opts, err
+		}
+		opts.Focus = &focus
+	}
+	if req.Background != "" {
+		background, err := imaging.ParseColor(req.Background)
+		if err != nil {
+			return opts, err
+		}
+		opts.Background = background
+	}
+	return opts, opts.Validate()
 }
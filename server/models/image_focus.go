@@ -0,0 +1,13 @@
+package models
+
+//ImageFocusReq sets the focal point of an image, in fractions of its width and height from the top left corner
+type ImageFocusReq struct {
+	X *float64 `json:"x" binding:"required,min=0,max=1"`
+	Y *float64 `json:"y" binding:"required,min=0,max=1"`
+}
+
+//ImageFocusRes model
+type ImageFocusRes struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/thanhtuan260593/file-server/database"
+)
+
+// ImageHistoryReq model bind a history of an image
+type ImageHistoryReq struct {
+	ID        uint `uri:"id" binding:"required"`
+	HistoryID uint `uri:"historyId" binding:"required"`
+}
+
+// ImageHistoryRes model
+type ImageHistoryRes struct {
+	ID         uint       `json:"id"`
+	Fullname   string     `json:"fullname"`
+	ActionType string     `json:"actionType"`
+	BackupPath string     `json:"backupPath,omitempty"`
+	PurgedAt   *time.Time `json:"purgedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// PurgeRes model
+type PurgeRes struct {
+	Purged int `json:"purged"`
+}
+
+// NewImageHistoryRes model
+func NewImageHistoryRes(history *database.FileHistory) *ImageHistoryRes {
+	rs := ImageHistoryRes{}
+	rs.ID = history.ID
+	rs.Fullname = history.Fullname
+	rs.ActionType = history.ActionType
+	rs.BackupPath = history.BackupPath
+	rs.PurgedAt = history.PurgedAt
+	rs.CreatedAt = history.CreatedAt
+	return &rs
+}
@@ -7,13 +7,103 @@ type ImageIDReq struct {
 	ID uint `uri:"id" binding:"required"`
 }
 
+//Page sizes of image listing, the binding of ImagesReq.PageSize follows MaxPageSize
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 1000
+)
+
 //ImagesReq model bind request images model
 type ImagesReq struct {
-	PageSize    uint     `form:"pageSize"`
+	PageSize    uint     `form:"pageSize" binding:"max=1000"`
 	PageCurrent uint     `form:"pageCurrent"`
 	OrderBy     []string `form:"orderBy"`
 	OrderDir    []string `form:"orderDir"`
 	Tags        []string `form:"tags"`
+	AllTags     []string `form:"allTags"`
+	AnyTags     []string `form:"anyTags"`
+	NoTags      []string `form:"noTags"`
+	UnderTags   []string `form:"underTags"`
+	ContentType string   `form:"contentType"`
+	ColorModel  string   `form:"colorModel"`
+	Checksum    string   `form:"checksum"`
+	MinSize     *int64   `form:"minSize"`
+	MaxSize     *int64   `form:"maxSize"`
+	MinWidth    *int     `form:"minWidth"`
+	MaxWidth    *int     `form:"maxWidth"`
+	MinHeight   *int     `form:"minHeight"`
+	MaxHeight   *int     `form:"maxHeight"`
+	Cursor      string   `form:"cursor"`
+}
+
+//Query of database from request model
+func (req *ImagesReq) Query() (*database.FileQuery, error) {
+	// tags is kept for compatibility, it means any of tags
+	tagLists := [][]string{req.AllTags, append(req.AnyTags, req.Tags...), req.NoTags, req.UnderTags}
+	for i, tags := range tagLists {
+		normalized, err := normalizeTags(tags)
+		if err != nil {
+			return nil, err
+		}
+		tagLists[i] = normalized
+	}
+	query := database.FileQuery{
+		AllTags:     tagLists[0],
+		AnyTags:     tagLists[1],
+		NoTags:      tagLists[2],
+		UnderTags:   tagLists[3],
+		Page:        req.PageCurrent,
+		Size:        req.PageSize,
+		ContentType: req.ContentType,
+		ColorModel:  req.ColorModel,
+		Checksum:    req.Checksum,
+		MinSize:     req.MinSize,
+		MaxSize:     req.MaxSize,
+		MinWidth:    req.MinWidth,
+		MaxWidth:    req.MaxWidth,
+		MinHeight:   req.MinHeight,
+		MaxHeight:   req.MaxHeight,
+	}
+	if query.Size == 0 {
+		query.Size = DefaultPageSize
+	}
+	// The cursor remembers its orders
+	if req.Cursor != "" {
+		cursor, err := database.DecodeCursor(req.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query.After = cursor
+		return &query, nil
+	}
+	query.Orders = make([]database.Order, len(req.OrderBy))
+	for i, by := range req.OrderBy {
+		dir := "asc"
+		if len(req.OrderDir) > i {
+			dir = req.OrderDir[i]
+		}
+		order, err := database.NewOrder(by, dir)
+		if err != nil {
+			return nil, err
+		}
+		query.Orders[i] = order
+	}
+	return &query, nil
+}
+
+// normalizeTags return tags in the form they are stored
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	normalized := make([]string, len(tags))
+	for i, tag := range tags {
+		var err error
+		if normalized[i], err = database.NormalizeTag(tag); err != nil {
+			return nil, err
+		}
+	}
+	return normalized, nil
 }
 
 //ImageRenameReq bind rename request model
@@ -21,6 +111,11 @@ type ImageRenameReq struct {
 	Name string `json:"name" binding:"required"`
 }
 
+//ImageCopyReq bind copy request model
+type ImageCopyReq struct {
+	Name string `json:"name" binding:"required"`
+}
+
 //ImageNewReq bind new file request model
 type ImageNewReq struct {
 	Name string   `form:"name" binding:"required"`
@@ -29,9 +124,41 @@ type ImageNewReq struct {
 
 //ImageInfoRes model
 type ImageInfoRes struct {
-	ID       uint     `json:"id"`
-	Fullname string   `json:"fullname"`
-	Tags     []string `json:"tags"`
+	ID          uint     `json:"id"`
+	Fullname    string   `json:"fullname"`
+	Tags        []string `json:"tags"`
+	Size        int64    `json:"size"`
+	ContentType string   `json:"contentType"`
+	Width       int      `json:"width"`
+	Height      int      `json:"height"`
+	ColorModel  string   `json:"colorModel"`
+	Checksum    string   `json:"checksum"`
+	// Focus is the focal point kept by crops, smart crop is used if it is not set
+	Focus *ImageFocusRes `json:"focus,omitempty"`
+}
+
+//ImagesRes model of a page of images
+type ImagesRes struct {
+	Items       []*ImageInfoRes `json:"items"`
+	Total       uint            `json:"total"`
+	PageSize    uint            `json:"pageSize"`
+	PageCurrent uint            `json:"pageCurrent"`
+	HasNext     bool            `json:"hasNext"`
+	NextCursor  string          `json:"nextCursor,omitempty"`
+}
+
+//NewImagesRes model, pageCurrent is meaningless when paginated by cursor
+func NewImagesRes(imgs []database.File, total, pageSize, pageCurrent uint, hasNext bool) *ImagesRes {
+	rs := ImagesRes{}
+	rs.Items = make([]*ImageInfoRes, len(imgs))
+	for i, img := range imgs {
+		rs.Items[i] = NewImageInfoRes(&img)
+	}
+	rs.Total = total
+	rs.PageSize = pageSize
+	rs.PageCurrent = pageCurrent
+	rs.HasNext = hasNext
+	return &rs
 }
 
 //NewImageInfoRes model
@@ -39,6 +166,15 @@ func NewImageInfoRes(img *database.File) *ImageInfoRes {
 	rs := ImageInfoRes{}
 	rs.Fullname = img.Fullname
 	rs.ID = img.ID
+	rs.Size = img.Size
+	rs.ContentType = img.ContentType
+	rs.Width = img.Width
+	rs.Height = img.Height
+	rs.ColorModel = img.ColorModel
+	rs.Checksum = img.Checksum
+	if img.FocusX != nil && img.FocusY != nil {
+		rs.Focus = &ImageFocusRes{X: *img.FocusX, Y: *img.FocusY}
+	}
 	if img.Tags != nil {
 		rs.Tags = make([]string, len(img.Tags))
 		for i, tag := range img.Tags {
@@ -1,7 +1,85 @@
 package models
 
+import (
+	"strings"
+
+	"github.com/thanhtuan260593/file-server/database"
+)
+
 //ImageTagReq model for add/remove tag from image
 type ImageTagReq struct {
 	ID  uint   `uri:"id" binding:"required"`
 	Tag string `uri:"tag" binding:"required"`
 }
+
+//TagName return the tag without the leading slash of wildcard path
+func (req *ImageTagReq) TagName() string {
+	return strings.TrimPrefix(req.Tag, "/")
+}
+
+//TagReq model bind a tag from query
+type TagReq struct {
+	Tag string `form:"tag" binding:"required"`
+}
+
+//TagRenameReq model for renaming a tag
+type TagRenameReq struct {
+	Tag  string `json:"tag" binding:"required"`
+	Name string `json:"name" binding:"required"`
+}
+
+//TagMergeReq model for merging source tag into target tag
+type TagMergeReq struct {
+	Source string `json:"source" binding:"required"`
+	Target string `json:"target" binding:"required"`
+}
+
+//TagRes model
+type TagRes struct {
+	ID    string `json:"id"`
+	Count uint   `json:"count"`
+}
+
+//TagNodeRes model is a node of tag tree.
+//Count is the number of images having exactly this tag, Total sums counts of the tag and its descendants
+type TagNodeRes struct {
+	ID       string        `json:"id"`
+	Name     string        `json:"name"`
+	Count    uint          `json:"count"`
+	Total    uint          `json:"total"`
+	Children []*TagNodeRes `json:"children,omitempty"`
+}
+
+//NewTagTree builds tag tree from tags sorted by id,
+//ancestors which are not tags themselves are added with zero count
+func NewTagTree(tags []database.TagUsage) []*TagNodeRes {
+	var roots []*TagNodeRes
+	nodes := make(map[string]*TagNodeRes)
+	for _, tag := range tags {
+		var parent *TagNodeRes
+		for _, id := range database.TagPath(tag.ID) {
+			node, ok := nodes[id]
+			if !ok {
+				node = &TagNodeRes{ID: id, Name: tagName(id)}
+				nodes[id] = node
+				if parent == nil {
+					roots = append(roots, node)
+				} else {
+					parent.Children = append(parent.Children, node)
+				}
+			}
+			node.Total += tag.Count
+			parent = node
+		}
+		parent.Count += tag.Count
+	}
+	return roots
+}
+
+// tagName is the last segment of a tag
+func tagName(id string) string {
+	if parent := database.ParentTag(id); parent != "" {
+		return id[len(parent)+1:]
+	}
+	return id
+}
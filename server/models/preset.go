@@ -0,0 +1,64 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/thanhtuan260593/file-server/imaging"
+)
+
+//ErrPresetNotFound is returned for an unknown preset name
+var ErrPresetNotFound = errors.New("preset-not-found")
+
+//Preset is a named transformation, it takes the same parameters as a resize request
+type Preset struct {
+	Width      uint    `json:"width"`
+	Height     uint    `json:"height"`
+	Mode       string  `json:"mode"`
+	Gravity    string  `json:"gravity"`
+	Background string  `json:"bg"`
+	Format     string  `json:"format"`
+	Quality    int     `json:"quality"`
+	Grayscale  bool    `json:"grayscale"`
+	Blur       float64 `json:"blur"`
+	Sharpen    float64 `json:"sharpen"`
+}
+
+//Request return the resize request of the preset for a file
+func (p Preset) Request(fileName string) ImageFileReq {
+	return ImageFileReq{
+		Width:      p.Width,
+		Height:     p.Height,
+		FileName:   fileName,
+		Format:     p.Format,
+		Quality:    p.Quality,
+		Mode:       p.Mode,
+		Gravity:    p.Gravity,
+		Background: p.Background,
+		Grayscale:  p.Grayscale,
+		Blur:       p.Blur,
+		Sharpen:    p.Sharpen,
+	}
+}
+
+//Validate checks the preset as a resize request would be checked
+func (p Preset) Validate() error {
+	req := p.Request("")
+	if _, err := req.Options(); err != nil {
+		return err
+	}
+	if p.Quality < 0 || p.Quality > 100 {
+		return imaging.ErrQualityInvalid
+	}
+	if p.Format != "" && p.Format != AutoFormat {
+		if _, err := imaging.FormatByName(p.Format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//ImagePresetReq model
+type ImagePresetReq struct {
+	Name     string `uri:"name" binding:"required"`
+	FileName string `uri:"path" binding:"required"`
+}
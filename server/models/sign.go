@@ -0,0 +1,13 @@
+package models
+
+//SignURLReq model, URL is the path and query of an image url
+type SignURLReq struct {
+	URL string `json:"url" binding:"required"`
+	// ExpiresIn is the lifetime of the signature in seconds, zero for a signature which never expires
+	ExpiresIn int64 `json:"expiresIn" binding:"min=0"`
+}
+
+//SignURLRes model
+type SignURLRes struct {
+	URL string `json:"url"`
+}
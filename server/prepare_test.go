@@ -14,6 +14,7 @@ var testFolder = "../_test"
 var testImageSourceFolder = "../_test/source"
 var testImagesStorageFolder = "../_test/images"
 var testImagesHistoryFolder = "../_test/_history"
+var testCacheFolder = "../_test/_cache"
 
 type URLImage struct {
 	DestName string
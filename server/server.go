@@ -18,15 +18,17 @@ import (
 	"github.com/thanhtuan260593/file-server/docs"
 
 	// swagger embed files
+	"github.com/thanhtuan260593/file-server/cache"
 	"github.com/thanhtuan260593/file-server/database"
-	localstorage "github.com/thanhtuan260593/file-server/storages/local"
+	"github.com/thanhtuan260593/file-server/storages"
 )
 
 //Server struct
 type Server struct {
 	db      *database.DB
 	config  *Config
-	storage *localstorage.Storage
+	storage storages.Backend
+	cache   *cache.Cache
 	router  *gin.Engine
 	port    string
 }
@@ -36,7 +38,18 @@ func NewServer(db *database.DB) *Server {
 	var sv = Server{}
 	sv.db = db
 	sv.config = NewConfig()
-	sv.storage = localstorage.NewStorage(sv.db)
+	storage, err := storages.Open(sv.config.StorageDriver, sv.db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sv.storage = storage
+	if sv.config.CacheMaxBytes > 0 {
+		if sv.cache, err = cache.New(sv.config.CacheDir, sv.config.CacheMaxBytes); err != nil {
+			log.Printf("Derived images are not cached: %v", err)
+		}
+	}
+	// files are also changed by the watcher and fsck, not only by handlers
+	sv.db.OnFileChange(sv.invalidateCache)
 	sv.port = ":5000"
 	port := os.Getenv("PORT")
 	if port != "" {
@@ -84,19 +97,37 @@ func (s *Server) SetupRouter() {
 	imageGroup := router.Group("images")
 
 	// Register public route
-	imageGroup.Static("/static", s.storage.WorkingDir)
-	imageGroup.GET("/size/:width/:height/*name", s.HandleResize)
+	imageGroup.StaticFS("/static", s.storage)
+	imageGroup.GET("/size/:width/:height/*name", s.checkSignature, s.HandleResize)
+	imageGroup.GET("/preset/:name/*path", s.checkSignature, s.HandlePreset)
 
 	// Register private route
 	adminGroup := router.Group("/admin")
 	adminGroup.GET("/images", s.HandleGetImages)
+	adminGroup.POST("/sign", s.HandleSignURL)
 	adminGroup.GET("/image/:id", s.HandleGetImageByID)
 	adminGroup.DELETE("/image/:id", s.HandleDeleteImage)
 	adminGroup.PUT("/image", s.HandleUploadImage)
 	adminGroup.POST("/image/:id/rename", s.HandleRenameImage)
+	adminGroup.POST("/image/:id/copy", s.HandleCopyImage)
 	adminGroup.POST("/image/:id/replace", s.HandleReplaceImage)
-	adminGroup.PUT("/image/:id/tag/:tag", s.HandleAddImageTag)
-	adminGroup.DELETE("/image/:id/tag/:tag", s.HandleRemoveImageTag)
+	adminGroup.PUT("/image/:id/focus", s.HandleSetImageFocus)
+	adminGroup.DELETE("/image/:id/focus", s.HandleDeleteImageFocus)
+	adminGroup.GET("/image/:id/history", s.HandleGetImageHistory)
+	adminGroup.GET("/image/:id/history/:historyId", s.HandleDownloadImageHistory)
+	adminGroup.POST("/image/:id/history/:historyId/restore", s.HandleRestoreImageHistory)
+	adminGroup.POST("/image/:id/undelete", s.HandleUndeleteImage)
+	adminGroup.DELETE("/trash", s.HandleEmptyTrash)
+	adminGroup.GET("/fsck", s.HandleCheckStorage)
+	adminGroup.POST("/fsck", s.HandleRepairStorage)
+	// tags may contain separators, so they are matched by a wildcard
+	adminGroup.PUT("/image/:id/tag/*tag", s.HandleAddImageTag)
+	adminGroup.DELETE("/image/:id/tag/*tag", s.HandleRemoveImageTag)
+	adminGroup.GET("/tags", s.HandleGetTags)
+	adminGroup.GET("/tags/tree", s.HandleGetTagTree)
+	adminGroup.DELETE("/tags", s.HandleDeleteTag)
+	adminGroup.POST("/tags/rename", s.HandleRenameTag)
+	adminGroup.POST("/tags/merge", s.HandleMergeTags)
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	s.router = router
 }
@@ -107,6 +138,18 @@ func (s *Server) Start() {
 		Addr:    s.port,
 		Handler: s.router,
 	}
+	// Purge expired backups and watch the storage in background until the server is shut down
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	storages.StartPurger(workerCtx, s.db, s.storage, s.config.Retention)
+	if watcher, ok := s.storage.(storages.Watcher); ok {
+		go func() {
+			if err := watcher.Watch(workerCtx); err != nil {
+				log.Printf("Storage watcher stopped: %v", err)
+			}
+		}()
+	}
+
 	// Initializing the server in a goroutine so that
 	// it won't block the graceful shutdown handling below
 	go func() {
@@ -116,7 +159,7 @@ func (s *Server) Start() {
 	}()
 	// Wait for interrupt signal to gracefully shutdown the server with
 	// a timeout of 5 seconds.
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	// kill (no param) default send syscall.SIGTERM
 	// kill -2 is syscall.SIGINT
 	// kill -9 is syscall.SIGKILL but can't be catch, so don't need add it
@@ -3,19 +3,28 @@ package server
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
 	"io"
+	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
+	"github.com/thanhtuan260593/file-server/cache"
 	"github.com/thanhtuan260593/file-server/database"
+	"github.com/thanhtuan260593/file-server/server/models"
+	"github.com/thanhtuan260593/file-server/signing"
+	"github.com/thanhtuan260593/file-server/storages"
 	localstorage "github.com/thanhtuan260593/file-server/storages/local"
 )
 
@@ -33,10 +42,14 @@ func setup() {
 
 func reset() {
 	server.db = database.NewClean(dbURL)
-	server.storage.WorkingDir = testImagesStorageFolder
-	server.storage.HistoryDir = testImagesHistoryFolder
-	localstorage.RemoveContents(server.storage.WorkingDir)
-	localstorage.RemoveContents(server.storage.HistoryDir)
+	store := server.storage.(*localstorage.Storage)
+	store.WorkingDir = testImagesStorageFolder
+	store.HistoryDir = testImagesHistoryFolder
+	localstorage.RemoveContents(store.WorkingDir)
+	localstorage.RemoveContents(store.HistoryDir)
+	localstorage.RemoveContents(testCacheFolder)
+	server.cache, _ = cache.New(testCacheFolder, 1<<20)
+	server.db.OnFileChange(server.invalidateCache)
 	addedFilePath = filepath.Join(testImageSourceFolder, imageURLs[0].DestName)
 }
 
@@ -135,6 +148,22 @@ func TestRenameFile(t *testing.T) {
 	assert.Equal(t, 200, recorder.Code)
 }
 
+func TestCopyFile(t *testing.T) {
+	t.Run("Add new file to copy", TestAddFile)
+	recorder := performJSONRequest(server.router,
+		"POST",
+		fmt.Sprintf("/admin/image/%v/copy", 1),
+		gin.H{"name": "copied.jpg"},
+	)
+	assert.Equal(t, 200, recorder.Code)
+	recorder = performJSONRequest(server.router,
+		"POST",
+		fmt.Sprintf("/admin/image/%v/copy", 1),
+		gin.H{"name": "copied.jpg"},
+	)
+	assert.Equal(t, 400, recorder.Code)
+}
+
 func TestRenameFileShouldFail(t *testing.T) {
 	reset()
 	recorder := performJSONRequest(server.router,
@@ -173,6 +202,26 @@ func TestDeleteFileShouldFail(t *testing.T) {
 	assert.Equal(t, 400, recorder.Code)
 }
 
+func TestAddInvalidFile(t *testing.T) {
+	reset()
+	fake := filepath.Join(os.TempDir(), "fake.png")
+	ioutil.WriteFile(fake, []byte("not an image"), 0644)
+	defer os.Remove(fake)
+	source := addedFilePath
+	addedFilePath = fake
+	defer func() { addedFilePath = source }()
+
+	recorder, err := requestAddFile("PUT", "/admin/image")
+	if err != nil {
+		assert.Fail(t, err.Error())
+		return
+	}
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	var res models.ErrorRes
+	json.Unmarshal(recorder.Body.Bytes(), &res)
+	assert.Equal(t, storages.ErrFileContentMismatch.Error(), res.Err)
+}
+
 func TestReplaceFile(t *testing.T) {
 	t.Run("Add new file to replace", TestAddFile)
 	addedFilePath = filepath.Join(testImageSourceFolder, imageURLs[4].DestName)
@@ -187,6 +236,88 @@ func TestReplaceFile(t *testing.T) {
 	assert.Equal(t, http.StatusOK, recorder.Code)
 }
 
+func TestGetImageHistory(t *testing.T) {
+	t.Run("Replace file to get history", TestReplaceFile)
+	var histories []models.ImageHistoryRes
+	recorder := performRequest(server.router, "GET", "/admin/image/1/history", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	json.Unmarshal(recorder.Body.Bytes(), &histories)
+	if !assert.Len(t, histories, 2) {
+		return
+	}
+	assert.Equal(t, "Created", histories[0].ActionType)
+	assert.Equal(t, "", histories[0].BackupPath)
+	assert.Equal(t, "Replaced", histories[1].ActionType)
+
+	original, _ := ioutil.ReadFile(filepath.Join(testImageSourceFolder, imageURLs[0].DestName))
+	recorder = performRequest(server.router, "GET", fmt.Sprintf("/admin/image/1/history/%v", histories[1].ID), nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, original, recorder.Body.Bytes())
+
+	recorder = performRequest(server.router, "GET", fmt.Sprintf("/admin/image/1/history/%v", histories[0].ID), nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	recorder = performRequest(server.router, "GET", "/admin/image/2/history", nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestRestoreImageHistory(t *testing.T) {
+	t.Run("Replace file to restore", TestReplaceFile)
+	var histories []models.ImageHistoryRes
+	recorder := performRequest(server.router, "GET", "/admin/image/1/history", nil)
+	json.Unmarshal(recorder.Body.Bytes(), &histories)
+	if !assert.Len(t, histories, 2) {
+		return
+	}
+	recorder = performRequest(server.router, "POST", fmt.Sprintf("/admin/image/1/history/%v/restore", histories[1].ID), nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = performRequest(server.router, "GET", "/admin/image/1/history", nil)
+	json.Unmarshal(recorder.Body.Bytes(), &histories)
+	if assert.Len(t, histories, 3) {
+		assert.Equal(t, "Restored", histories[2].ActionType)
+		assert.NotEmpty(t, histories[2].BackupPath)
+	}
+}
+
+func TestUndeleteImage(t *testing.T) {
+	t.Run("Delete file to undelete", TestDeleteFile)
+	recorder := performRequest(server.router, "POST", "/admin/image/1/undelete", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	recorder = performRequest(server.router, "GET", "/admin/image/1", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	recorder = performRequest(server.router, "POST", "/admin/image/1/undelete", nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestEmptyTrash(t *testing.T) {
+	t.Run("Delete file to empty trash", TestDeleteFile)
+	var rs models.PurgeRes
+	recorder := performRequest(server.router, "DELETE", "/admin/trash", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	json.Unmarshal(recorder.Body.Bytes(), &rs)
+	assert.Equal(t, 1, rs.Purged)
+	recorder = performRequest(server.router, "POST", "/admin/image/1/undelete", nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestCheckStorage(t *testing.T) {
+	t.Run("Add new file to check", TestAddFile)
+	var problems []models.FsckProblemRes
+	recorder := performRequest(server.router, "GET", "/admin/fsck", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	json.Unmarshal(recorder.Body.Bytes(), &problems)
+	assert.Empty(t, problems)
+
+	os.Remove(filepath.Join(testImagesStorageFolder, filepath.Base(addedFilePath)))
+	recorder = performRequest(server.router, "POST", "/admin/fsck", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	json.Unmarshal(recorder.Body.Bytes(), &problems)
+	if assert.Len(t, problems, 1) {
+		assert.Equal(t, "missing-file", problems[0].Kind)
+		assert.True(t, problems[0].Fixed)
+	}
+}
+
 func TestGetImagesInfo(t *testing.T) {
 	t.Run("Add new file to replace", TestAddFile)
 	myURL := "/admin/images?orderBy%5B%5D=id&orderBy%5B%5D=fullname&pageSize=10"
@@ -196,6 +327,99 @@ func TestGetImagesInfo(t *testing.T) {
 	assert.Equal(t, http.StatusOK, recorder.Code)
 }
 
+func TestGetImagesFilteredByMetadata(t *testing.T) {
+	t.Run("Add new file to filter", TestAddFile)
+	recorder := performRequest(server.router, "GET", "/admin/images?pageSize=10&contentType=image&minWidth=1&orderBy=size&orderDir=desc", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var images models.ImagesRes
+	if err := json.Unmarshal(recorder.Body.Bytes(), &images); err != nil {
+		t.Error(err)
+		return
+	}
+	assert.Equal(t, 1, len(images.Items))
+	assert.Equal(t, "image/jpeg", images.Items[0].ContentType)
+	assert.NotEmpty(t, images.Items[0].Checksum)
+
+	recorder = performRequest(server.router, "GET", "/admin/images?pageSize=10&contentType=image/png", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	images = models.ImagesRes{}
+	json.Unmarshal(recorder.Body.Bytes(), &images)
+	assert.Equal(t, 0, len(images.Items))
+	assert.Equal(t, uint(0), images.Total)
+}
+
+func TestGetImagesWithInvalidOrderShouldFail(t *testing.T) {
+	reset()
+	recorder := performRequest(server.router, "GET", "/admin/images?pageSize=10&orderBy=id;drop", nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	recorder = performRequest(server.router, "GET", "/admin/images?pageSize=10&orderBy=id&orderDir=up", nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestGetImagesPagination(t *testing.T) {
+	reset()
+	for _, img := range imageURLs[:3] {
+		addedFilePath = filepath.Join(testImageSourceFolder, img.DestName)
+		recorder, err := requestAddFile("PUT", "/admin/image")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	}
+	var images models.ImagesRes
+	recorder := performRequest(server.router, "GET", "/admin/images?pageSize=2&pageCurrent=0", nil)
+	json.Unmarshal(recorder.Body.Bytes(), &images)
+	assert.Equal(t, uint(3), images.Total)
+	assert.Equal(t, 2, len(images.Items))
+	assert.True(t, images.HasNext)
+
+	images = models.ImagesRes{}
+	recorder = performRequest(server.router, "GET", "/admin/images?pageSize=2&pageCurrent=1", nil)
+	json.Unmarshal(recorder.Body.Bytes(), &images)
+	assert.Equal(t, uint(3), images.Total)
+	assert.Equal(t, 1, len(images.Items))
+	assert.False(t, images.HasNext)
+}
+
+func TestGetImagesDefaultPageSize(t *testing.T) {
+	t.Run("Add images to list", TestGetImagesPagination)
+	var images models.ImagesRes
+	recorder := performRequest(server.router, "GET", "/admin/images", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	json.Unmarshal(recorder.Body.Bytes(), &images)
+	assert.Equal(t, uint(models.DefaultPageSize), images.PageSize)
+	assert.Equal(t, 3, len(images.Items))
+	assert.False(t, images.HasNext)
+
+	recorder = performRequest(server.router, "GET", "/admin/images?pageSize=4294967295", nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestGetImagesByCursor(t *testing.T) {
+	t.Run("Add images to paginate", TestGetImagesPagination)
+	var images models.ImagesRes
+	recorder := performRequest(server.router, "GET", "/admin/images?pageSize=2&orderBy=fullname&orderDir=desc", nil)
+	json.Unmarshal(recorder.Body.Bytes(), &images)
+	assert.Equal(t, 2, len(images.Items))
+	assert.NotEmpty(t, images.NextCursor)
+	names := []string{images.Items[0].Fullname, images.Items[1].Fullname}
+
+	cursor := images.NextCursor
+	images = models.ImagesRes{}
+	recorder = performRequest(server.router, "GET", "/admin/images?pageSize=2&cursor="+cursor, nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	json.Unmarshal(recorder.Body.Bytes(), &images)
+	assert.Equal(t, 1, len(images.Items))
+	assert.False(t, images.HasNext)
+	assert.Empty(t, images.NextCursor)
+	names = append(names, images.Items[0].Fullname)
+	assert.Equal(t, []string{"IMG_1003.JPG", "IMG_1002.JPG", "IMG_1001.JPG"}, names)
+
+	recorder = performRequest(server.router, "GET", "/admin/images?pageSize=2&cursor=broken", nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
 func TestAddTag(t *testing.T) {
 	t.Run("Add new file to replace", TestAddFile)
 	recorder := performRequest(server.router, "PUT", "/admin/image/1/tag/test_tag", nil)
@@ -210,8 +434,12 @@ func TestAdd2Tags(t *testing.T) {
 
 func TestRemoveTag(t *testing.T) {
 	t.Run("Add new tag to delete", TestAddTag)
-	recorder := performRequest(server.router, "DELETE", "/admin/image/1/tag/test_tag", nil)
+	recorder := performRequest(server.router, "DELETE", "/admin/image/1/tag/%20test_tag%20", nil)
 	assert.Equal(t, http.StatusOK, recorder.Code)
+	var images models.ImagesRes
+	recorder = performRequest(server.router, "GET", "/admin/images?pageSize=10&allTags=test_tag", nil)
+	json.Unmarshal(recorder.Body.Bytes(), &images)
+	assert.Empty(t, images.Items, "the tag is removed by its normalized name")
 }
 
 func TestGetImagesHavingTags(t *testing.T) {
@@ -221,6 +449,78 @@ func TestGetImagesHavingTags(t *testing.T) {
 	assert.Equal(t, http.StatusOK, recorder.Code)
 }
 
+func TestGetImagesByTagOperators(t *testing.T) {
+	t.Run("Add new tag to get", TestAddTag)
+	cases := map[string]int{
+		"allTags=test_tag":                   1,
+		"allTags=test_tag&allTags=other_tag": 0,
+		"anyTags=test_tag&anyTags=other_tag": 1,
+		"noTags=test_tag":                    0,
+		"anyTags=test_tag&noTags=other_tag":  1,
+		"tags=test_tag&tags=test_tag":        1,
+		"allTags=%20test_tag%20":             1,
+		"noTags=/test_tag/":                  0,
+	}
+	for params, count := range cases {
+		var images models.ImagesRes
+		recorder := performRequest(server.router, "GET", "/admin/images?pageSize=10&"+params, nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		json.Unmarshal(recorder.Body.Bytes(), &images)
+		assert.Equal(t, count, len(images.Items), params)
+		assert.Equal(t, uint(count), images.Total, params)
+	}
+	recorder := performRequest(server.router, "GET", "/admin/images?pageSize=10&allTags=%20/%20", nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestManageTags(t *testing.T) {
+	t.Run("Add new tag to manage", TestAddTag)
+	recorder := performJSONRequest(server.router, "POST", "/admin/tags/rename", gin.H{"tag": "test_tag", "name": "renamed_tag"})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	recorder = performJSONRequest(server.router, "POST", "/admin/tags/merge", gin.H{"source": "renamed_tag", "target": "merged_tag"})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var tags []models.TagRes
+	recorder = performRequest(server.router, "GET", "/admin/tags", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	json.Unmarshal(recorder.Body.Bytes(), &tags)
+	assert.Equal(t, []models.TagRes{{ID: "merged_tag", Count: 1}}, tags)
+
+	recorder = performRequest(server.router, "DELETE", "/admin/tags?tag=merged_tag", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	recorder = performRequest(server.router, "DELETE", "/admin/tags?tag=merged_tag", nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestGetTagTree(t *testing.T) {
+	t.Run("Add new file to tag", TestAddFile)
+	for _, tag := range []string{"campaign/2026/spring", "brand:acme"} {
+		recorder := performRequest(server.router, "PUT", "/admin/image/1/tag/"+tag, nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	}
+
+	var tree []models.TagNodeRes
+	recorder := performRequest(server.router, "GET", "/admin/tags/tree", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	json.Unmarshal(recorder.Body.Bytes(), &tree)
+	if assert.Len(t, tree, 2) {
+		assert.Equal(t, "brand", tree[0].ID)
+		assert.Equal(t, "acme", tree[0].Children[0].Name)
+		assert.Equal(t, uint(0), tree[1].Count)
+		assert.Equal(t, uint(1), tree[1].Total)
+		assert.Equal(t, "campaign/2026/spring", tree[1].Children[0].Children[0].ID)
+	}
+
+	var images models.ImagesRes
+	recorder = performRequest(server.router, "GET", "/admin/images?underTags=campaign&pageSize=10", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	json.Unmarshal(recorder.Body.Bytes(), &images)
+	assert.Equal(t, 1, len(images.Items))
+
+	recorder = performRequest(server.router, "DELETE", "/admin/image/1/tag/campaign/2026/spring", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
 func TestGetImageByID(t *testing.T) {
 	t.Run("Add image to get", TestAddFile)
 	recorder := performRequest(server.router, "GET", "/admin/image/1", nil)
@@ -277,6 +577,214 @@ func TestGetResizedImageShouldFail(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, recorder.Code)
 
 	t.Run("Add image to get", TestAddFile)
-	recorder = performRequest(server.router, "GET", "/images/size/400/0/IMG_1001.JPG", nil)
+	recorder = performRequest(server.router, "GET", "/images/size/400/0/IMG_1001.bmp", nil)
 	assert.Equal(t, http.StatusBadRequest, recorder.Code)
 }
+
+func TestGetResizedImageFormat(t *testing.T) {
+	t.Run("Add jpeg image to convert", TestAddFile)
+	recorder := performRequest(server.router, "GET", "/images/size/100/0/IMG_1001.JPG?format=gif", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "image/gif", recorder.Header().Get("Content-Type"))
+	assert.Empty(t, recorder.Header().Get("Vary"))
+
+	req, _ := http.NewRequest("GET", "/images/size/100/0/IMG_1001.JPG?format=auto&quality=50", nil)
+	req.Header.Set("Accept", "image/webp,image/gif,*/*;q=0.8")
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "image/gif", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept", recorder.Header().Get("Vary"))
+
+	for _, query := range []string{"format=webp", "format=bmp", "quality=101"} {
+		recorder = performRequest(server.router, "GET", "/images/size/100/0/IMG_1001.JPG?"+query, nil)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, query)
+	}
+}
+
+func TestGetResizedImageModes(t *testing.T) {
+	t.Run("Add jpeg image to crop", TestAddFile)
+	for query, size := range map[string]image.Point{
+		"":                        image.Pt(100, 100),
+		"mode=fill&gravity=north": image.Pt(100, 100),
+		"mode=fill&fx=0.2&fy=0.8": image.Pt(100, 100),
+		"mode=pad&bg=000":         image.Pt(100, 100),
+	} {
+		recorder := performRequest(server.router, "GET", "/images/size/100/100/IMG_1001.JPG?"+query, nil)
+		if !assert.Equal(t, http.StatusOK, recorder.Code, query) {
+			continue
+		}
+		config, _, err := image.DecodeConfig(recorder.Body)
+		if assert.NoError(t, err, query) {
+			assert.Equal(t, size, image.Pt(config.Width, config.Height), query)
+		}
+	}
+	for _, query := range []string{"mode=stretch", "mode=fill&gravity=up", "mode=fill&fx=2&fy=0", "mode=fill&fx=0.5", "mode=pad&bg=white", "blur=1e300", "sharpen=100.5"} {
+		recorder := performRequest(server.router, "GET", "/images/size/100/100/IMG_1001.JPG?"+query, nil)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, query)
+	}
+}
+
+func TestImageFocus(t *testing.T) {
+	t.Run("Add image to focus", TestAddFile)
+	recorder := performJSONRequest(server.router, "PUT", "/admin/image/1/focus", gin.H{"x": 0.25, "y": 0})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = performRequest(server.router, "GET", "/admin/image/1", nil)
+	var info models.ImageInfoRes
+	if assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &info)) && assert.NotNil(t, info.Focus) {
+		assert.Equal(t, models.ImageFocusRes{X: 0.25, Y: 0}, *info.Focus)
+	}
+	recorder = performRequest(server.router, "GET", "/images/size/100/100/IMG_1001.JPG?mode=fill", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	for _, data := range []gin.H{{"x": 1.5, "y": 0.5}, {"x": 0.5}} {
+		recorder = performJSONRequest(server.router, "PUT", "/admin/image/1/focus", data)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "%v", data)
+	}
+
+	recorder = performRequest(server.router, "DELETE", "/admin/image/1/focus", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	info = models.ImageInfoRes{}
+	if assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &info)) {
+		assert.Nil(t, info.Focus)
+	}
+}
+
+func TestGetPresetImage(t *testing.T) {
+	t.Run("Add image to get by preset", TestAddFile)
+	recorder := performRequest(server.router, "GET", "/images/preset/thumb/IMG_1001.JPG", nil)
+	if assert.Equal(t, http.StatusOK, recorder.Code) {
+		config, _, err := image.DecodeConfig(recorder.Body)
+		if assert.NoError(t, err) {
+			assert.Equal(t, image.Pt(160, 160), image.Pt(config.Width, config.Height))
+		}
+	}
+	recorder = performRequest(server.router, "GET", "/images/preset/unknown/IMG_1001.JPG", nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	// presets are clamped like resize requests
+	server.config.Presets["huge"] = models.Preset{Width: server.config.MaxWidth * 2}
+	defer delete(server.config.Presets, "huge")
+	recorder = performRequest(server.router, "GET", "/images/preset/huge/IMG_1001.JPG", nil)
+	if assert.Equal(t, http.StatusOK, recorder.Code) {
+		config, _, err := image.DecodeConfig(recorder.Body)
+		if assert.NoError(t, err) {
+			assert.Equal(t, int(server.config.MaxWidth), config.Width)
+		}
+	}
+}
+
+func TestNewPresets(t *testing.T) {
+	presets := newPresets(`{"thumb": {"width": 64, "height": 64, "mode": "fill"}, "gray": {"width": 100, "grayscale": true}, "bad": {"mode": "stretch"}, "blurry": {"width": 100, "blur": 1e300}}`)
+	assert.Equal(t, uint(64), presets["thumb"].Width)
+	assert.True(t, presets["gray"].Grayscale)
+	assert.Contains(t, presets, "card")
+	assert.NotContains(t, presets, "bad")
+	assert.NotContains(t, presets, "blurry")
+	assert.Equal(t, len(DefaultPresets), len(newPresets("{")))
+}
+
+func TestSignedURL(t *testing.T) {
+	t.Run("Add image to sign", TestAddFile)
+	server.config.SignKey = []byte("secret")
+	server.config.RequireSignature = true
+	defer func() {
+		server.config.SignKey = nil
+		server.config.RequireSignature = false
+	}()
+
+	recorder := performRequest(server.router, "GET", "/images/preset/thumb/IMG_1001.JPG", nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = performJSONRequest(server.router, "POST", "/admin/sign", gin.H{"url": "/images/size/100/0/IMG_1001.JPG?mode=fit", "expiresIn": 60})
+	var signed models.SignURLRes
+	if assert.Equal(t, http.StatusOK, recorder.Code) && assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &signed)) {
+		recorder = performRequest(server.router, "GET", signed.URL, nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		recorder = performRequest(server.router, "GET", strings.Replace(signed.URL, "/100/", "/4000/", 1), nil)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	}
+
+	url, err := signing.SignURL(server.config.SignKey, "/images/preset/thumb/IMG_1001.JPG", time.Now().Add(-time.Minute))
+	if assert.NoError(t, err) {
+		recorder = performRequest(server.router, "GET", url, nil)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	}
+}
+
+func TestCachedResize(t *testing.T) {
+	t.Run("Add image to cache", TestAddFile)
+	first := performRequest(server.router, "GET", "/images/size/100/0/IMG_1001.JPG", nil)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.True(t, server.cache.Size() > 0)
+	second := performRequest(server.router, "GET", "/images/size/100/0/IMG_1001.JPG", nil)
+	assert.Equal(t, first.Body.Bytes(), second.Body.Bytes())
+	assert.Equal(t, first.Header().Get("Content-Type"), second.Header().Get("Content-Type"))
+
+	recorder := performJSONRequest(server.router, "POST", "/admin/image/1/rename", gin.H{"name": "renamed.jpg"})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, int64(0), server.cache.Size())
+	recorder = performRequest(server.router, "GET", "/images/size/100/0/IMG_1001.JPG", nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	performRequest(server.router, "GET", "/images/size/100/0/renamed.jpg", nil)
+	assert.True(t, server.cache.Size() > 0)
+	// a change made outside the server is invalidated once fsck repairs it
+	performRequest(server.router, "GET", "/images/size/100/0/renamed.jpg", nil)
+	assert.True(t, server.cache.Size() > 0)
+	replaced, _ := ioutil.ReadFile(filepath.Join(testImageSourceFolder, imageURLs[1].DestName))
+	ioutil.WriteFile(filepath.Join(testImagesStorageFolder, "renamed.jpg"), replaced, 0644)
+	recorder = performRequest(server.router, "POST", "/admin/fsck", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, int64(0), server.cache.Size())
+
+	recorder = performRequest(server.router, "DELETE", "/admin/image/1", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, int64(0), server.cache.Size())
+}
+
+func TestGetResizedJPEG(t *testing.T) {
+	t.Run("Add jpeg image to get", TestAddFile)
+	recorder := performRequest(server.router, "GET", "/images/size/400/0/IMG_1001.JPG", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "image/jpeg", recorder.Header().Get("Content-Type"))
+	_, format, err := image.Decode(recorder.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+}
+
+func TestHandlersWithFakeBackend(t *testing.T) {
+	reset()
+	saved := server
+	defer func() { server = saved }()
+	backend := newFakeBackend(saved.db)
+	server = &Server{db: saved.db, config: saved.config, storage: backend}
+	server.SetupRouter()
+
+	recorder, err := requestAddFile("PUT", "/admin/image")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	name := filepath.Base(addedFilePath)
+	assert.Contains(t, backend.files, name)
+
+	recorder = performRequest(server.router, "GET", "/images/size/10/0/"+name, nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	backend.err = errors.New("backend-failed")
+	recorder = performRequest(server.router, "DELETE", "/admin/image/1", nil)
+	assert.Equal(t, 400, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "backend-failed")
+	assert.Contains(t, backend.files, name, "a failed delete keeps the file")
+	recorder = performJSONRequest(server.router, "POST", "/admin/image/1/copy", gin.H{"name": "copied.jpg"})
+	assert.Equal(t, 400, recorder.Code)
+	assert.NotContains(t, backend.files, "copied.jpg")
+
+	backend.err = nil
+	recorder = performRequest(server.router, "DELETE", "/admin/image/1", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.NotContains(t, backend.files, name)
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thanhtuan260593/file-server/server/models"
+	"github.com/thanhtuan260593/file-server/signing"
+)
+
+// HandleSignURL godocs
+// @Id SignURL
+// @Summary Sign an image url
+// @Description Signed urls are accepted by the resize and preset routes when signatures are required
+// @Accept application/json
+// @Produce json
+// @Param model body models.SignURLReq true "sign model"
+// @Success 200 {object} models.SignURLRes
+// @Failure 400 {object} models.ErrorRes
+// @Router /admin/sign [post]
+func (s *Server) HandleSignURL(c *gin.Context) {
+	var model models.SignURLReq
+	if err := errorJSON(c, c.BindJSON(&model)); err != nil {
+		return
+	}
+	var expires time.Time
+	if model.ExpiresIn > 0 {
+		expires = time.Now().Add(time.Duration(model.ExpiresIn) * time.Second)
+	}
+	signed, err := signing.SignURL(s.config.SignKey, model.URL, expires)
+	if err != nil {
+		errorJSON(c, err)
+		return
+	}
+	c.JSON(200, models.SignURLRes{URL: signed})
+}
+
+// checkSignature rejects requests without a valid signature if the config requires one
+func (s *Server) checkSignature(c *gin.Context) {
+	if !s.config.RequireSignature {
+		return
+	}
+	errorJSON(c, signing.Verify(s.config.SignKey, c.Request.URL.Path, c.Request.URL.Query(), time.Now()))
+}
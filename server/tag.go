@@ -0,0 +1,105 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/thanhtuan260593/file-server/server/models"
+)
+
+// HandleGetTags godocs
+// @Id GetTags
+// @Summary Get list of tags with number of images having each tag
+// @Produce  json
+// @Success 200 {array} models.TagRes
+// @Failure 400 {object} models.ErrorRes
+// @Router /admin/tags [get]
+func (s *Server) HandleGetTags(c *gin.Context) {
+	tags, err := s.db.GetTags()
+	if err != nil {
+		errorJSON(c, err)
+		return
+	}
+	rs := make([]models.TagRes, len(tags))
+	for i, tag := range tags {
+		rs[i] = models.TagRes{ID: tag.ID, Count: tag.Count}
+	}
+	c.JSON(200, rs)
+}
+
+// HandleGetTagTree godocs
+// @Id GetTagTree
+// @Summary Get tags as a tree
+// @Description Tags are split by "/" and ":", e.g. "campaign/2026" is under "campaign" and "brand:acme" is under "brand"
+// @Produce  json
+// @Success 200 {array} models.TagNodeRes
+// @Failure 400 {object} models.ErrorRes
+// @Router /admin/tags/tree [get]
+func (s *Server) HandleGetTagTree(c *gin.Context) {
+	tags, err := s.db.GetTags()
+	if err != nil {
+		errorJSON(c, err)
+		return
+	}
+	c.JSON(200, models.NewTagTree(tags))
+}
+
+// HandleRenameTag godocs
+// @Id RenameTag
+// @Summary Rename a tag of every image
+// @Description Tags under the tag are renamed with it
+// @Accept application/json
+// @Param model body models.TagRenameReq true "rename model"
+// @Success 200
+// @Failure 400 {object} models.ErrorRes
+// @Router /admin/tags/rename [post]
+func (s *Server) HandleRenameTag(c *gin.Context) {
+	var model models.TagRenameReq
+	if err := errorJSON(c, c.BindJSON(&model)); err != nil {
+		return
+	}
+	if err := s.db.RenameTag(model.Tag, model.Name); err != nil {
+		errorJSON(c, err)
+		return
+	}
+	c.Status(200)
+}
+
+// HandleMergeTags godocs
+// @Id MergeTags
+// @Summary Merge source tag into target tag
+// @Description Images having source tag get target tag, then source tag is deleted, a source having tags under it is not merged
+// @Accept application/json
+// @Param model body models.TagMergeReq true "merge model"
+// @Success 200
+// @Failure 400 {object} models.ErrorRes
+// @Router /admin/tags/merge [post]
+func (s *Server) HandleMergeTags(c *gin.Context) {
+	var model models.TagMergeReq
+	if err := errorJSON(c, c.BindJSON(&model)); err != nil {
+		return
+	}
+	if err := s.db.MergeTags(model.Source, model.Target); err != nil {
+		errorJSON(c, err)
+		return
+	}
+	c.Status(200)
+}
+
+// HandleDeleteTag godocs
+// @Id DeleteTag
+// @Summary Delete a tag and remove it from every image
+// @Description A tag having tags under it is not deleted
+// @Param model query models.TagReq true "query model"
+// @Success 200
+// @Failure 400 {object} models.ErrorRes
+// @Router /admin/tags [delete]
+func (s *Server) HandleDeleteTag(c *gin.Context) {
+	var model models.TagReq
+	if err := errorJSON(c, c.BindQuery(&model)); err != nil {
+		return
+	}
+	if err := s.db.DeleteTag(model.Tag); err != nil {
+		errorJSON(c, err)
+		return
+	}
+	c.Status(200)
+}
@@ -2,49 +2,51 @@ package server
 
 import (
 	"io"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/thanhtuan260593/file-server/server/models"
-	localstorage "github.com/thanhtuan260593/file-server/storages/local"
+	"github.com/thanhtuan260593/file-server/storages"
 )
 
-// func parseImageToReader(lc *localstorage.Storage, img image.Image, ext string) (io.Reader, int64, error) {
-// 	if !lc.IsValidExt(ext) {
-// 		return nil, 0, localstorage.ErrFileExtInvalid
-// 	}
-// 	var resizedBuffer bytes.Buffer
-// 	var mybytes []byte
-// 	var err error
-// 	switch ext {
-// 	case localstorage.PngExt:
-// 		var encoder = png.Encoder{
-// 			CompressionLevel: png.BestCompression,
-// 		}
-// 		if err := encoder.Encode(&resizedBuffer, img); err != nil {
-// 			return nil, 0, err
-// 		}
-// 		mybytes = resizedBuffer.Bytes()
-// 		mybytes, err = pngquant.CompressBytes(mybytes, "1")
-// 		if err != nil {
-// 			return nil, 0, err
-// 		}
-// 	default:
-// 		return nil, 0, localstorage.ErrFileExtInvalid
-// 	}
+// maxFormOverhead is the room left in a request body for form fields besides the uploaded file
+const maxFormOverhead = 1 << 20
 
-// 	reader := bytes.NewReader(mybytes)
-// 	return reader, int64(resizedBuffer.Len()), nil
-// }
+// limitUpload rejects a request body which is too large for the upload policy before it is parsed
+func (s *Server) limitUpload(c *gin.Context) error {
+	max := s.config.Upload.MaxBytes
+	if max <= 0 {
+		return nil
+	}
+	if c.Request.ContentLength > max+maxFormOverhead {
+		return storages.ErrUploadTooLarge
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, max+maxFormOverhead)
+	return nil
+}
 
-func getFileFromGinContext(c *gin.Context) (io.Reader, error) {
+// getFileFromGinContext opens the uploaded file, which is validated by the upload policy as a file named name.
+// The caller closes the file
+func (s *Server) getFileFromGinContext(c *gin.Context, name string) (io.ReadCloser, error) {
 	fileHeader, _ := c.FormFile("file")
 	if fileHeader == nil {
-		return nil, localstorage.ErrFileNotFound
+		return nil, storages.ErrFileNotFound
+	}
+	if max := s.config.Upload.MaxBytes; max > 0 && fileHeader.Size > max {
+		return nil, storages.ErrUploadTooLarge
 	}
 	reader, err := fileHeader.Open()
 	if err != nil {
 		return nil, err
 	}
+	if err := s.config.Upload.Validate(name, reader); err != nil {
+		reader.Close()
+		return nil, err
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		reader.Close()
+		return nil, err
+	}
 	return reader, nil
 }
 
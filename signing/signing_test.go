@@ -0,0 +1,61 @@
+package signing
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var key = []byte("secret")
+
+func verifyURL(key []byte, rawURL string, now time.Time) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	return Verify(key, u.Path, u.Query(), now)
+}
+
+func TestSignURL(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	signed, err := SignURL(key, "/images/size/100/0/a b.png?mode=fit", time.Time{})
+	if assert.NoError(t, err) {
+		assert.NoError(t, verifyURL(key, signed, now))
+		assert.Equal(t, ErrSignatureInvalid, verifyURL([]byte("other"), signed, now))
+	}
+
+	signed, err = SignURL(key, "/images/preset/thumb/a.png", now.Add(time.Minute))
+	if assert.NoError(t, err) {
+		assert.NoError(t, verifyURL(key, signed, now))
+		assert.Equal(t, ErrSignatureExpired, verifyURL(key, signed, now.Add(2*time.Minute)))
+	}
+
+	_, err = SignURL(nil, "/images/preset/thumb/a.png", time.Time{})
+	assert.Equal(t, ErrKeyMissing, err)
+}
+
+func TestVerifyTampered(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	signed, _ := SignURL(key, "/images/size/100/0/a.png?mode=fit", now.Add(time.Minute))
+	u, _ := url.Parse(signed)
+
+	assert.Equal(t, ErrSignatureInvalid, Verify(key, "/images/size/4000/0/a.png", u.Query(), now))
+
+	query := u.Query()
+	query.Set("mode", "fill")
+	assert.Equal(t, ErrSignatureInvalid, Verify(key, u.Path, query, now))
+
+	query = u.Query()
+	query.Set(ExpiresParam, "9999999999")
+	assert.Equal(t, ErrSignatureInvalid, Verify(key, u.Path, query, now))
+
+	query = u.Query()
+	query.Add("blur", "10")
+	assert.Equal(t, ErrSignatureInvalid, Verify(key, u.Path, query, now))
+
+	query = u.Query()
+	query.Del(SignatureParam)
+	assert.Equal(t, ErrSignatureMissing, Verify(key, u.Path, query, now))
+}
@@ -0,0 +1,202 @@
+package storages
+
+import (
+	"context"
+	"os"
+
+	"github.com/thanhtuan260593/file-server/database"
+)
+
+// Kinds of problem found by Fsck
+const (
+	// ProblemMissingFile is a tracked file without content, fixed by untracking the file
+	ProblemMissingFile = "missing-file"
+	// ProblemUntrackedFile is a file without row, fixed by tracking the file
+	ProblemUntrackedFile = "untracked-file"
+	// ProblemMissingBackup is a history whose backup is gone, fixed by marking the history as purged
+	ProblemMissingBackup = "missing-backup"
+	// ProblemChecksumMismatch is a file whose content differs from its metadata, fixed by reading the metadata again.
+	// Backends sharing contents by blobs move the content to its own blob
+	ProblemChecksumMismatch = "checksum-mismatch"
+	// ProblemMissingChecksum is a file tracked without checksum, fixed by reading the metadata
+	ProblemMissingChecksum = "missing-checksum"
+)
+
+// Lister is implemented by backends which can list their working files
+type Lister interface {
+	// ListFiles calls fn with client path of every file in working zone
+	ListFiles(fn func(path string) error) error
+}
+
+// BlobStore is implemented by backends which share contents between files by blobs
+type BlobStore interface {
+	// DropBlob removes the content of a blob if no file references it
+	DropBlob(id string) error
+	// RelinkFile tracks the current content of a file changed outside the server,
+	// the content is moved to its own blob and the old blob is released in one transaction
+	RelinkFile(file *database.File) error
+}
+
+// Watcher is implemented by backends which can track changes made outside the server
+type Watcher interface {
+	// Watch keeps database in sync with the changes until ctx is done
+	Watch(ctx context.Context) error
+}
+
+// Problem found by Fsck
+type Problem struct {
+	Kind      string
+	Path      string
+	FileID    uint
+	HistoryID uint
+	Fixed     bool
+}
+
+// Fsck compares backend with database and reports problems, the problems are fixed if fix is true.
+// Untracked files are only reported for backends implementing Lister.
+// Files of operations in progress are skipped, their content and rows are not consistent yet.
+func Fsck(db *database.DB, backend Backend, fix bool) ([]Problem, error) {
+	var problems []Problem
+	report := func(problem Problem, repair func() error) error {
+		if problem.Kind != ProblemMissingBackup {
+			// the intent is checked once the problem is seen, an operation may have started since the listing
+			busy, err := pending(db, problem.Path)
+			if err != nil || busy {
+				return err
+			}
+		}
+		if fix {
+			if err := repair(); err != nil {
+				return err
+			}
+			problem.Fixed = true
+		}
+		problems = append(problems, problem)
+		return nil
+	}
+
+	files, err := db.GetAllFiles()
+	if err != nil {
+		return nil, err
+	}
+	tracked := make(map[string]bool, len(files))
+	for i := range files {
+		file := &files[i]
+		tracked[file.Fullname] = true
+		meta, err := readBackendMetadata(backend, file.Fullname)
+		if os.IsNotExist(err) {
+			err = report(Problem{Kind: ProblemMissingFile, Path: file.Fullname, FileID: file.ID}, func() error {
+				return untrackMissingFile(db, backend, file)
+			})
+		} else if err == nil && file.Checksum == "" {
+			// files tracked before checksums were introduced
+			err = report(Problem{Kind: ProblemMissingChecksum, Path: file.Fullname, FileID: file.ID}, func() error {
+				meta.Apply(file)
+				return db.UpdateFileContent(file)
+			})
+		} else if err == nil && meta.Checksum != file.Checksum {
+			err = report(Problem{Kind: ProblemChecksumMismatch, Path: file.Fullname, FileID: file.ID}, func() error {
+				if store, ok := backend.(BlobStore); ok {
+					return store.RelinkFile(file)
+				}
+				meta.Apply(file)
+				return db.UpdateFileContent(file)
+			})
+		}
+		if err != nil {
+			return problems, err
+		}
+	}
+
+	if lister, ok := backend.(Lister); ok {
+		if err := lister.ListFiles(func(path string) error {
+			if tracked[path] {
+				return nil
+			}
+			return report(Problem{Kind: ProblemUntrackedFile, Path: path}, func() error {
+				// the file may be tracked by an operation committed since the listing
+				if _, err := db.GetFileByName(path); err != database.ErrNotFound {
+					return err
+				}
+				meta, err := readBackendMetadata(backend, path)
+				if err != nil {
+					return err
+				}
+				file := database.File{Fullname: path}
+				meta.Apply(&file)
+				return db.CreateFile(&file)
+			})
+		}); err != nil {
+			return problems, err
+		}
+	}
+
+	histories, err := db.GetBackupHistories()
+	if err != nil {
+		return problems, err
+	}
+	for i := range histories {
+		history := &histories[i]
+		f, err := backend.OpenHistory(history.BackupPath)
+		if err == nil {
+			f.Close()
+			continue
+		}
+		if !os.IsNotExist(err) {
+			return problems, err
+		}
+		problem := Problem{Kind: ProblemMissingBackup, Path: history.BackupPath, FileID: history.FileID, HistoryID: history.ID}
+		if err := report(problem, func() error {
+			return db.MarkHistoryPurged(history)
+		}); err != nil {
+			return problems, err
+		}
+	}
+	return problems, nil
+}
+
+// pending return true if an operation on path is neither committed nor rolled back
+func pending(db *database.DB, path string) (bool, error) {
+	intents, err := db.GetIntents()
+	if err != nil {
+		return false, err
+	}
+	for _, intent := range intents {
+		if intent.Path == path || intent.NewPath == path {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// untrackMissingFile deletes the row of a file without content and releases its blob in one transaction
+func untrackMissingFile(db *database.DB, backend Backend, file *database.File) error {
+	var dropped bool
+	if err := db.Atomic(func(tx *database.DB) error {
+		if err := tx.DeleteFile(file, ""); err != nil {
+			return err
+		}
+		if file.BlobID == nil {
+			return nil
+		}
+		var err error
+		dropped, err = tx.ReleaseBlob(*file.BlobID)
+		return err
+	}); err != nil {
+		return err
+	}
+	if store, ok := backend.(BlobStore); ok && dropped {
+		return store.DropBlob(*file.BlobID)
+	}
+	return nil
+}
+
+// readBackendMetadata reads metadata of a working file through the file system of backend
+func readBackendMetadata(backend Backend, path string) (*Metadata, error) {
+	f, err := backend.Open("/" + path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadMetadata(path, f)
+}
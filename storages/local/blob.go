@@ -0,0 +1,127 @@
+package localstorage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/jinzhu/gorm"
+	"github.com/thanhtuan260593/file-server/database"
+)
+
+// blobPath return physical path of a blob, blobs are spread in sub directories by their first bytes
+func (lc *Storage) blobPath(id string) string {
+	if len(id) < 4 {
+		return filepath.Join(lc.BlobDir, id)
+	}
+	return filepath.Join(lc.BlobDir, id[:2], id[2:4], id)
+}
+
+// writeBlob write content to blob zone once, return the id of the blob.
+// The blob is referenced in database when the operation using it is committed,
+// lc.blobs must be held until the blob is recorded in the intent of the operation, see takeBlob
+func (lc *Storage) writeBlob(reader io.Reader) (string, error) {
+	if err := os.MkdirAll(lc.BlobDir, os.ModePerm); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempFile(lc.BlobDir, ".upload-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), reader)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	id := hex.EncodeToString(hash.Sum(nil))
+	path := lc.blobPath(id)
+	if fileExists(path) {
+		return id, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return "", err
+	}
+	return id, renameFile(tmp.Name(), path)
+}
+
+// linkBlob make the content of a blob available at serverPath
+func (lc *Storage) linkBlob(id, serverPath string) error {
+	if err := os.Link(lc.blobPath(id), serverPath); err == nil {
+		return nil
+	}
+	// Blob zone may be in another device, give up deduplication
+	return copyFileContents(lc.blobPath(id), serverPath)
+}
+
+// takeBlob writes reader to a blob used by intent and links it to serverPath.
+// The blob is recorded in intent under lc.blobs, so it is not dropped until the operation ends
+func (lc *Storage) takeBlob(intent *database.Intent, reader io.Reader, serverPath string) error {
+	lc.blobs.Lock()
+	defer lc.blobs.Unlock()
+	id, err := lc.writeBlob(reader)
+	if err != nil {
+		return err
+	}
+	intent.BlobID = id
+	if err := lc.db.SaveIntent(intent); err != nil {
+		return err
+	}
+	if err := failpoint(intent.Action + ":blob"); err != nil {
+		return err
+	}
+	return lc.linkBlob(id, serverPath)
+}
+
+// acquireBlob add a reference to the blob of file in transaction tx
+func acquireBlob(tx *database.DB, file *database.File) error {
+	if file.BlobID == nil {
+		return nil
+	}
+	return tx.AcquireBlob(&database.Blob{ID: *file.BlobID, Size: file.Size})
+}
+
+// releaseBlob remove a reference of a blob in transaction tx,
+// return true if the blob file should be removed after the transaction is committed
+func releaseBlob(tx *database.DB, id *string) (bool, error) {
+	if id == nil {
+		return false, nil
+	}
+	return tx.ReleaseBlob(*id)
+}
+
+// DropBlob removes the file of a blob which is no longer referenced
+func (lc *Storage) DropBlob(id string) error {
+	return lc.dropUnusedBlob(id, 0)
+}
+
+// dropUnusedBlob removes a blob file which is neither referenced in database
+// nor used by an operation in progress other than the intent except.
+// lc.blobs is held, so a blob can not be taken by an operation between the check and the removal
+func (lc *Storage) dropUnusedBlob(id string, except uint) error {
+	lc.blobs.Lock()
+	defer lc.blobs.Unlock()
+	if _, err := lc.db.GetBlob(id); !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+	intents, err := lc.db.GetIntents()
+	if err != nil {
+		return err
+	}
+	for _, intent := range intents {
+		if intent.BlobID == id && intent.ID != except {
+			return nil
+		}
+	}
+	return removeIfExists(lc.blobPath(id))
+}
@@ -0,0 +1,260 @@
+package localstorage
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/thanhtuan260593/file-server/database"
+)
+
+// TempPrefix starts names of temporary files in working zone
+const TempPrefix = ".tmp-"
+
+// DefaultIntentLease is how long an operation of another instance is considered in progress
+var DefaultIntentLease = time.Hour
+
+// failpoint is called between the steps of an operation, tests use it to inject failures and crashes
+var failpoint = func(step string) error { return nil }
+
+// run executes an operation logged by intent.
+// The intent is saved before do changes files, then save and the intent deletion are committed together.
+// If do or save fails, files are rolled back by the intent.
+func (lc *Storage) run(intent *database.Intent, do func() error, save func(tx *database.DB) error) error {
+	intent.Owner = lc.Instance
+	if err := lc.db.CreateIntent(intent); err != nil {
+		return err
+	}
+	// the watcher ignores the changes made here
+	var paths []string
+	for _, path := range []string{intent.Path, intent.NewPath, intent.TempPath, restoringPath(intent.TempPath)} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	lc.writes.begin(paths...)
+	defer lc.writes.end(paths...)
+	err := do()
+	if err == nil {
+		err = failpoint(intent.Action + ":commit")
+	}
+	if err == nil {
+		err = lc.db.CompleteIntent(intent, save)
+	}
+	if err != nil {
+		if rbErr := lc.rollback(intent); rbErr != nil {
+			// Keep the intent, it is rolled back again on next start
+			log.Printf("Can not roll back %s %s: %v", intent.Action, intent.Path, rbErr)
+			return err
+		}
+		lc.db.DeleteIntent(intent)
+		return err
+	}
+	return nil
+}
+
+// Recover rolls back operations interrupted by a crash, it is called before the storage is used.
+// Operations of other instances are left to them, unless they are older than IntentLease
+func (lc *Storage) Recover() error {
+	intents, err := lc.db.GetAbandonedIntents(lc.Instance, time.Now().Add(-lc.IntentLease))
+	if err != nil {
+		return err
+	}
+	for i := range intents {
+		intent := &intents[i]
+		if err := lc.rollback(intent); err != nil {
+			return fmt.Errorf("roll back %s %s: %w", intent.Action, intent.Path, err)
+		}
+		if err := lc.db.DeleteIntent(intent); err != nil {
+			return err
+		}
+		log.Printf("Rolled back %s %s", intent.Action, intent.Path)
+	}
+	return nil
+}
+
+// rollback puts files changed by an uncommitted operation back to their state before the operation.
+// It works from any step of the operation and can be run more than once.
+func (lc *Storage) rollback(intent *database.Intent) error {
+	path := lc.GetPhysicalWorkingPath(intent.Path)
+	switch intent.Action {
+	case database.CreateAction:
+		// The path may be taken by another file, it is removed only if this operation created it
+		if intent.Published || sameFile(lc.GetPhysicalWorkingPath(intent.TempPath), path) {
+			if err := removeIfExists(path); err != nil {
+				return err
+			}
+		}
+	case database.RenameAction:
+		newPath := lc.GetPhysicalWorkingPath(intent.NewPath)
+		if (intent.Published || sameFile(path, newPath)) && fileExists(newPath) {
+			if !fileExists(path) {
+				if err := linkOrCopy(newPath, path); err != nil {
+					return err
+				}
+			}
+			if err := os.Remove(newPath); err != nil {
+				return err
+			}
+			if err := syncDir(filepath.Dir(newPath)); err != nil {
+				return err
+			}
+		}
+	case database.DeleteAction:
+		// The file is removed only after its backup is complete
+		if !fileExists(path) {
+			if err := lc.restoreBackup(intent); err != nil {
+				return err
+			}
+		}
+	case database.ReplaceAction:
+		// The file is replaced by renaming the temporary file, after its backup is complete
+		if !fileExists(lc.GetPhysicalWorkingPath(intent.TempPath)) {
+			if err := lc.restoreBackup(intent); err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range []string{intent.TempPath, restoringPath(intent.TempPath)} {
+		if name != "" {
+			if err := removeIfExists(lc.GetPhysicalWorkingPath(name)); err != nil {
+				return err
+			}
+		}
+	}
+	if intent.BackupPath != "" {
+		if err := removeIfExists(lc.GetPhysicalHistoricalPath(intent.BackupPath)); err != nil {
+			return err
+		}
+	}
+	if intent.BlobID != "" {
+		return lc.dropUnusedBlob(intent.BlobID, intent.ID)
+	}
+	return nil
+}
+
+// restoreBackup puts the backup of an intent back to its path at once,
+// nothing is done if the backup was not made.
+// A replaced file is overwritten, a deleted file is restored only if its path was not taken meanwhile,
+// otherwise the backup is kept in history zone
+func (lc *Storage) restoreBackup(intent *database.Intent) error {
+	backup := lc.GetPhysicalHistoricalPath(intent.BackupPath)
+	if intent.BackupPath == "" || !fileExists(backup) {
+		return nil
+	}
+	restoring := lc.GetPhysicalWorkingPath(restoringPath(intent.TempPath))
+	if err := removeIfExists(restoring); err != nil {
+		return err
+	}
+	if _, err := copyFile(backup, restoring, false); err != nil {
+		return err
+	}
+	path := lc.GetPhysicalWorkingPath(intent.Path)
+	if intent.Action == database.ReplaceAction {
+		return renameFile(restoring, path)
+	}
+	err := linkOrCopy(restoring, path)
+	if err == ErrFileExisted {
+		log.Printf("Can not restore %s, the path is taken, its backup is kept at %s", intent.Path, intent.BackupPath)
+		intent.BackupPath = ""
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// restoringPath is the temporary file used to restore a backup
+func restoringPath(tempPath string) string {
+	if tempPath == "" {
+		return ""
+	}
+	return tempPath + ".restore"
+}
+
+// tempPath return a new temporary client path next to clientPath
+func tempPath(clientPath string) string {
+	return filepath.Join(filepath.Dir(clientPath),
+		fmt.Sprintf("%s%d-%s", TempPrefix, time.Now().UnixNano(), filepath.Base(clientPath)))
+}
+
+// backup copies a working file to an unused path in history zone and records the path in intent.
+// The backup is created exclusively, so a backup made by another operation is never overwritten
+func (lc *Storage) backup(intent *database.Intent) error {
+	src := lc.GetPhysicalWorkingPath(intent.Path)
+	if err := os.MkdirAll(filepath.Dir(lc.GetPhysicalHistoricalPath(intent.Path)), os.ModePerm); err != nil {
+		return err
+	}
+	ext := filepath.Ext(intent.Path)
+	name := strings.TrimSuffix(intent.Path, ext)
+	backupPath := intent.Path
+	for count := 1; ; count++ {
+		err := linkOrCopy(src, lc.GetPhysicalHistoricalPath(backupPath))
+		if err == nil {
+			break
+		}
+		if err != ErrFileExisted || count >= MaxDuplicateFile {
+			return err
+		}
+		backupPath = fmt.Sprintf("%v_%v%v", name, count, ext)
+	}
+	intent.BackupPath = backupPath
+	if err := lc.db.SaveIntent(intent); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(lc.GetPhysicalHistoricalPath(backupPath)))
+}
+
+// publish moves a new file from the temporary path of intent to its path at once,
+// so clients never see a partly written file
+func (lc *Storage) publish(intent *database.Intent) error {
+	if err := failpoint(intent.Action + ":written"); err != nil {
+		return err
+	}
+	temp := lc.GetPhysicalWorkingPath(intent.TempPath)
+	if err := lc.link(intent, temp, lc.GetPhysicalWorkingPath(intent.Path)); err != nil {
+		return err
+	}
+	return removeIfExists(temp)
+}
+
+// link creates dst with the content of src for intent, ErrFileExisted is returned if dst is taken.
+// The intent is marked as published, so a rollback removes dst
+func (lc *Storage) link(intent *database.Intent, src, dst string) error {
+	if err := linkOrCopy(src, dst); err != nil {
+		return err
+	}
+	intent.Published = true
+	if err := lc.db.SaveIntent(intent); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(dst))
+}
+
+// writeContent writes reader to serverPath and reads metadata of the content into file.
+// serverPath is a temporary file, the content is synced to disk before it is moved to the real path.
+// In content addressable mode, the content is written to blob zone then linked to serverPath.
+func (lc *Storage) writeContent(intent *database.Intent, file *database.File, reader io.Reader, serverPath string) error {
+	if err := os.MkdirAll(filepath.Dir(serverPath), os.ModePerm); err != nil {
+		return err
+	}
+	if lc.ContentAddressable {
+		if err := lc.takeBlob(intent, reader, serverPath); err != nil {
+			return err
+		}
+		file.BlobID = &intent.BlobID
+	} else if err := writeFile(serverPath, reader); err != nil {
+		return err
+	}
+	meta, err := readMetadata(file.Fullname, serverPath)
+	if err != nil {
+		return err
+	}
+	meta.Apply(file)
+	return nil
+}
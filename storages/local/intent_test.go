@@ -0,0 +1,232 @@
+package localstorage
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thanhtuan260593/file-server/database"
+)
+
+var errInjected = errors.New("injected")
+
+type crash struct{}
+
+// failAt makes step fail, the failure is a crash if crashed is true
+func failAt(step string, crashed bool) {
+	failpoint = func(current string) error {
+		if current != step {
+			return nil
+		}
+		if crashed {
+			panic(crash{})
+		}
+		return errInjected
+	}
+}
+
+// runCrashing runs an operation which may be stopped by a crash
+func runCrashing(operation func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(crash); !ok {
+				panic(r)
+			}
+			err = errInjected
+		}
+	}()
+	return operation()
+}
+
+func countFiles(dir string) int {
+	count := 0
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			count++
+		}
+		return nil
+	})
+	return count
+}
+
+// assertConsistent checks every tracked file has its content in working zone and nothing else is there
+func assertConsistent(t *testing.T, historyCount int) {
+	files, err := store.db.GetFiles(&database.FileQuery{Size: 100})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	tracked := make(map[string]bool)
+	for _, f := range files {
+		tracked[f.Fullname] = true
+		meta, err := readMetadata(f.Fullname, store.GetPhysicalWorkingPath(f.Fullname))
+		if assert.NoError(t, err) {
+			assert.Equal(t, f.Checksum, meta.Checksum, f.Fullname)
+		}
+	}
+	filepath.Walk(store.WorkingDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(store.WorkingDir, path)
+			assert.True(t, tracked[rel], "%s is not tracked", rel)
+		}
+		return nil
+	})
+	assert.Equal(t, historyCount, countFiles(store.HistoryDir))
+	intents, _ := store.db.GetIntents()
+	assert.Empty(t, intents)
+}
+
+func TestOperationFailures(t *testing.T) {
+	defer func() { failpoint = func(string) error { return nil } }()
+	source := filepath.Join(testImageSourceFolder, addedFile.DestName)
+	replacement := filepath.Join(testImageSourceFolder, replacedFile.DestName)
+	operations := map[string]func() error{
+		"Created": func() error {
+			reader, _ := os.Open(replacement)
+			defer reader.Close()
+			_, err := store.AddFile(reader, "new.jpg")
+			return err
+		},
+		"Replaced": func() error {
+			reader, _ := os.Open(replacement)
+			defer reader.Close()
+			_, err := store.ReplaceFile(addedFile.DestName, reader)
+			return err
+		},
+		"Renamed": func() error {
+			_, err := store.RenameFile(addedFile.DestName, "renamed/"+addedFile.DestName)
+			return err
+		},
+		"Deleted": func() error {
+			_, err := store.DeleteFile(addedFile.DestName)
+			return err
+		},
+	}
+	steps := []string{
+		"Created:blob", "Created:written", "Created:commit",
+		"Replaced:blob", "Replaced:written", "Replaced:backup", "Replaced:commit",
+		"Renamed:commit",
+		"Deleted:backup", "Deleted:commit",
+	}
+	for _, contentAddressable := range []bool{false, true} {
+		for _, step := range steps {
+			for _, crashed := range []bool{false, true} {
+				if !contentAddressable && strings.HasSuffix(step, ":blob") {
+					continue
+				}
+				reset()
+				store.ContentAddressable = contentAddressable
+				reader, _ := os.Open(source)
+				if _, err := store.AddFile(reader, addedFile.DestName); err != nil {
+					reader.Close()
+					t.Error(err)
+					return
+				}
+				reader.Close()
+
+				failAt(step, crashed)
+				err := runCrashing(operations[strings.SplitN(step, ":", 2)[0]])
+				failpoint = func(string) error { return nil }
+				assert.Equal(t, errInjected, err, step)
+				if crashed {
+					if err := store.Recover(); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+				assertConsistent(t, 0)
+			}
+		}
+	}
+	store.ContentAddressable = false
+
+	// A blob written by a rolled back operation is removed
+	assert.Equal(t, 1, countFiles(store.BlobDir))
+}
+
+func TestRecoverDoesNotTouchCommittedFiles(t *testing.T) {
+	t.Run("Replace file before recovering", TestReplaceFile)
+	content, _ := ioutil.ReadFile(store.GetPhysicalWorkingPath(addedFile.DestName))
+	if err := store.Recover(); err != nil {
+		t.Error(err)
+		return
+	}
+	recovered, _ := ioutil.ReadFile(store.GetPhysicalWorkingPath(addedFile.DestName))
+	assert.Equal(t, content, recovered)
+	assertConsistent(t, 1)
+}
+
+func TestRollbackKeepsFilesOfOthers(t *testing.T) {
+	defer func() { failpoint = func(string) error { return nil } }()
+	reset()
+	other := []byte("created meanwhile")
+	failpoint = func(step string) error {
+		if step == "Created:written" {
+			return ioutil.WriteFile(store.GetPhysicalWorkingPath("new.jpg"), other, 0644)
+		}
+		return nil
+	}
+	reader, _ := os.Open(filepath.Join(testImageSourceFolder, addedFile.DestName))
+	defer reader.Close()
+	_, err := store.AddFile(reader, "new.jpg")
+	assert.Equal(t, ErrFileExisted, err)
+	content, err := ioutil.ReadFile(store.GetPhysicalWorkingPath("new.jpg"))
+	assert.NoError(t, err)
+	assert.Equal(t, other, content)
+	intents, _ := store.db.GetIntents()
+	assert.Empty(t, intents)
+}
+
+func TestDropBlobKeepsPendingBlob(t *testing.T) {
+	reset()
+	id, err := store.writeBlob(strings.NewReader("pending"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	intent := &database.Intent{Action: database.CreateAction, Path: "pending.jpg", BlobID: id}
+	if err := store.db.CreateIntent(intent); err != nil {
+		t.Error(err)
+		return
+	}
+	assert.NoError(t, store.DropBlob(id))
+	assert.True(t, fileExists(store.blobPath(id)), "a blob used by an operation in progress is kept")
+	assert.NoError(t, store.dropUnusedBlob(id, intent.ID))
+	assert.False(t, fileExists(store.blobPath(id)))
+}
+
+func TestRecoverLeavesOperationsOfOtherInstances(t *testing.T) {
+	reset()
+	intents := []*database.Intent{
+		{Action: database.CreateAction, Path: "busy.jpg", Published: true, Owner: "other"},
+		{Action: database.CreateAction, Path: "expired.jpg", Published: true, Owner: "other",
+			CreatedAt: time.Now().Add(-2 * store.IntentLease)},
+		{Action: database.CreateAction, Path: "crashed.jpg", Published: true, Owner: store.Instance},
+	}
+	for _, intent := range intents {
+		if err := ioutil.WriteFile(store.GetPhysicalWorkingPath(intent.Path), []byte(intent.Path), 0644); err != nil {
+			t.Error(err)
+			return
+		}
+		if err := store.db.CreateIntent(intent); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if err := store.Recover(); err != nil {
+		t.Error(err)
+		return
+	}
+	assert.True(t, fileExists(store.GetPhysicalWorkingPath("busy.jpg")))
+	assert.False(t, fileExists(store.GetPhysicalWorkingPath("expired.jpg")))
+	assert.False(t, fileExists(store.GetPhysicalWorkingPath("crashed.jpg")))
+	left, _ := store.db.GetIntents()
+	if assert.Len(t, left, 1) {
+		assert.Equal(t, "busy.jpg", left[0].Path)
+	}
+}
@@ -4,109 +4,263 @@ import (
 	"image"
 	"io"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/thanhtuan260593/file-server/database"
+	"github.com/thanhtuan260593/file-server/storages"
 )
 
+func init() {
+	storages.Register(DriverName, func(db *database.DB) (storages.Backend, error) {
+		local := NewStorage(db)
+		if err := local.Recover(); err != nil {
+			return nil, err
+		}
+		// files are tracked once interrupted operations are rolled back
+		if isInit := os.Getenv("INIT_SAMPLE_DATA"); isInit != "" {
+			if v, err := strconv.ParseBool(isInit); err == nil && v {
+				local.CreateMissingFiles()
+			}
+		}
+		return local, nil
+	})
+}
+
 // Storage file storage
 type Storage struct {
 	WorkingDir string
 	HistoryDir string
 	ValidExts  []string
-	db         *database.DB
+	// ContentAddressable stores each distinct content once in BlobDir,
+	// files in WorkingDir are hard links to their blobs
+	ContentAddressable bool
+	BlobDir            string
+	// Names checks the names of new files
+	Names storages.NamePolicy
+	// WatchChanges keeps database in sync with changes made in WorkingDir outside the server, see Watch
+	WatchChanges bool
+	WatchDelay   time.Duration
+	// Instance names this server among the servers sharing the database, its hostname by default.
+	// Recover rolls back the operations of Instance and the operations older than IntentLease
+	Instance    string
+	IntentLease time.Duration
+	db          *database.DB
+	writes      writeLog
+	// blobs serializes taking blobs by operations and dropping unused blobs
+	blobs sync.Mutex
 }
 
 // NewStorage return new LocalStorage
 func NewStorage(db *database.DB) *Storage {
 	var local = Storage{}
 	local.db = db
-	local.ValidExts = []string{PngExt, SvgExt}
+	local.ValidExts = []string{PngExt, SvgExt, JpgExt, JpegExt, GifExt, WebpExt}
 	local.WorkingDir = DefaultWorkingDir
 	local.HistoryDir = DefaultHistoryDir
+	local.BlobDir = DefaultBlobDir
+	local.WatchDelay = DefaultWatchDelay
+	local.Instance, _ = os.Hostname()
+	local.IntentLease = DefaultIntentLease
+	local.Names = storages.NewNamePolicy()
 
-	//Try get IMAGE_WORKING_DIR and IMAGE_HISTORY_DIR from os enviroment
+	//Try get IMAGE_WORKING_DIR, IMAGE_HISTORY_DIR and IMAGE_BLOB_DIR from os enviroment
 	if w := os.Getenv("IMAGE_WORKING_DIR"); w != "" {
 		local.WorkingDir = w
 	}
 	if w := os.Getenv("IMAGE_HISTORY_DIR"); w != "" {
 		local.HistoryDir = w
 	}
-
-	if isInit := os.Getenv("INIT_SAMPLE_DATA"); isInit != "" {
-		if v, err := strconv.ParseBool(isInit); err == nil && v {
-			local.CreateMissingFiles()
-		}
+	if w := os.Getenv("IMAGE_BLOB_DIR"); w != "" {
+		local.BlobDir = w
 	}
-	return &local
-}
-
-func (lc *Storage) physicalAddFile(reader io.Reader, fileName string) (string, error) {
-	serverPath, clientPath, err := lc.correctFileName(fileName)
-	log.Println(serverPath, clientPath)
-	if err != nil {
-		return "", err
+	if v, err := strconv.ParseBool(os.Getenv("IMAGE_CONTENT_ADDRESSABLE")); err == nil {
+		local.ContentAddressable = v
 	}
-	if err := os.MkdirAll(filepath.Dir(serverPath), os.ModePerm); err != nil {
-		return "", err
+	if v, err := strconv.ParseBool(os.Getenv("IMAGE_WATCH")); err == nil {
+		local.WatchChanges = v
 	}
-	out, err := os.Create(serverPath)
-	if err != nil {
-		return "", err
+	if d, err := time.ParseDuration(os.Getenv("IMAGE_WATCH_DELAY")); err == nil && d > 0 {
+		local.WatchDelay = d
+	}
+	if w := os.Getenv("IMAGE_INSTANCE"); w != "" {
+		local.Instance = w
 	}
-	defer out.Close()
-	_, err = io.Copy(out, reader)
-	return clientPath, err
+	if d, err := time.ParseDuration(os.Getenv("IMAGE_INTENT_LEASE")); err == nil && d > 0 {
+		local.IntentLease = d
+	}
+	return &local
 }
 
 // AddFile from fileheader
 func (lc *Storage) AddFile(reader io.Reader, fileName string) (*database.File, error) {
-	clientPath, err := lc.physicalAddFile(reader, fileName)
+	_, clientPath, err := lc.correctFileName(fileName)
 	if err != nil {
 		return nil, err
 	}
-	// Save new file to database if this file created successfully
 	fileModel := database.File{Fullname: clientPath}
-	err = lc.db.CreateFile(&fileModel)
-
-	// If failed to save to database, delete the file
+	intent := &database.Intent{Action: database.CreateAction, Path: clientPath, TempPath: tempPath(clientPath)}
+	err = lc.run(intent, func() error {
+		if err := lc.writeContent(intent, &fileModel, reader, lc.GetPhysicalWorkingPath(intent.TempPath)); err != nil {
+			return err
+		}
+		return lc.publish(intent)
+	}, func(tx *database.DB) error {
+		if err := acquireBlob(tx, &fileModel); err != nil {
+			return err
+		}
+		return tx.CreateFile(&fileModel)
+	})
 	if err != nil {
-		lc.DeleteFile(clientPath)
 		return nil, err
 	}
 	return &fileModel, nil
 }
 
-// RollbackNewFile will try to rollback of action newfile
-func (lc *Storage) RollbackNewFile(path string) (err error) {
-	lc.DeleteFile(path)
-	return
-}
-
 // ReplaceFile in storage
-func (lc *Storage) ReplaceFile(path string, file io.Reader) (string, error) {
+func (lc *Storage) ReplaceFile(path string, reader io.Reader) (string, error) {
 	// Find file from database, if no file found, return error
-	_, err := lc.db.GetFileByName(path)
+	file, err := lc.db.GetFileByName(path)
 	if err != nil {
 		return "", err
 	}
+	return lc.replaceFile(file, reader, (*database.DB).ReplaceFile)
+}
 
-	// Delete physical file
-	log.Printf("Try delete file %s", path)
-	_, backupPath, _, _, _, deleteErr := lc.physicalDeleteFile(path)
+// replaceFile writes new content of a file, the old content is backed up to history zone.
+// The new content is written to a temporary file which then replaces the file at once.
+// save records the new content with the backup path in database
+func (lc *Storage) replaceFile(file *database.File, reader io.Reader, save func(*database.DB, *database.File, string) error) (string, error) {
+	oldBlobID := file.BlobID
+	intent := &database.Intent{
+		Action:   database.ReplaceAction,
+		Path:     file.Fullname,
+		TempPath: tempPath(file.Fullname),
+	}
+	var dropped bool
+	err := lc.run(intent, func() error {
+		if err := lc.writeContent(intent, file, reader, lc.GetPhysicalWorkingPath(intent.TempPath)); err != nil {
+			return err
+		}
+		if err := failpoint(intent.Action + ":written"); err != nil {
+			return err
+		}
+		if err := lc.backup(intent); err != nil {
+			return err
+		}
+		if err := failpoint(intent.Action + ":backup"); err != nil {
+			return err
+		}
+		return renameFile(lc.GetPhysicalWorkingPath(intent.TempPath), lc.GetPhysicalWorkingPath(intent.Path))
+	}, func(tx *database.DB) (err error) {
+		if err = acquireBlob(tx, file); err != nil {
+			return err
+		}
+		if dropped, err = releaseBlob(tx, oldBlobID); err != nil {
+			return err
+		}
+		return save(tx, file, intent.BackupPath)
+	})
+	if err != nil {
+		file.BlobID = oldBlobID
+		return "", err
+	}
+	if dropped {
+		lc.dropUnusedBlob(*oldBlobID, 0)
+	}
+	return intent.BackupPath, nil
+}
 
-	// Create new physical file
-	log.Printf("Try add file %s", path)
-	_, err = lc.physicalAddFile(file, path)
+// RelinkFile tracks the current content of a file changed outside the server.
+// In content addressable mode the content is written to its own blob which replaces the file,
+// the old blob is released when the new one is acquired
+func (lc *Storage) RelinkFile(file *database.File) error {
+	oldBlobID := file.BlobID
+	intent := &database.Intent{
+		Action:   database.ReplaceAction,
+		Path:     file.Fullname,
+		TempPath: tempPath(file.Fullname),
+	}
+	var dropped bool
+	err := lc.run(intent, func() error {
+		reader, err := os.Open(lc.GetPhysicalWorkingPath(file.Fullname))
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		if err := lc.writeContent(intent, file, reader, lc.GetPhysicalWorkingPath(intent.TempPath)); err != nil {
+			return err
+		}
+		return renameFile(lc.GetPhysicalWorkingPath(intent.TempPath), lc.GetPhysicalWorkingPath(intent.Path))
+	}, func(tx *database.DB) (err error) {
+		if err := acquireBlob(tx, file); err != nil {
+			return err
+		}
+		if dropped, err = releaseBlob(tx, oldBlobID); err != nil {
+			return err
+		}
+		return tx.UpdateFileContent(file)
+	})
+	if err != nil {
+		file.BlobID = oldBlobID
+		return err
+	}
+	if dropped {
+		lc.dropUnusedBlob(*oldBlobID, 0)
+	}
+	return nil
+}
 
-	// If failed to create file, rollback action delete file
-	if err != nil && deleteErr == nil {
-		lc.RollbackDeleteFile(path, backupPath)
-		return "", err
+// RestoreFile puts the backup content of a history back to its file.
+// A deleted file is undeleted, the content of an existed file is moved to history zone first
+func (lc *Storage) RestoreFile(history *database.FileHistory) (*database.File, error) {
+	if !history.HasBackup() {
+		return nil, ErrFileNotFound
 	}
-	return backupPath, nil
+	file, err := lc.db.GetFileByIDUnscoped(history.FileID)
+	if err != nil {
+		return nil, err
+	}
+	backup, err := os.Open(lc.GetPhysicalHistoricalPath(history.BackupPath))
+	if err != nil {
+		return nil, ErrFileNotFound
+	}
+	defer backup.Close()
+	if file.DeletedAt == nil {
+		if _, err := lc.replaceFile(file, backup, (*database.DB).RestoreFile); err != nil {
+			return nil, err
+		}
+		return file, nil
+	}
+	return file, lc.undeleteFile(file, backup)
+}
+
+// undeleteFile writes content of a deleted file back to its path in working zone
+func (lc *Storage) undeleteFile(file *database.File, reader io.Reader) error {
+	// the name was checked when the file was created
+	serverPath, err := lc.workingPath(file.Fullname)
+	if err != nil {
+		return err
+	}
+	if fileExists(serverPath) {
+		return ErrFileExisted
+	}
+	intent := &database.Intent{Action: database.CreateAction, Path: file.Fullname, TempPath: tempPath(file.Fullname)}
+	return lc.run(intent, func() error {
+		if err := lc.writeContent(intent, file, reader, lc.GetPhysicalWorkingPath(intent.TempPath)); err != nil {
+			return err
+		}
+		return lc.publish(intent)
+	}, func(tx *database.DB) error {
+		if err := acquireBlob(tx, file); err != nil {
+			return err
+		}
+		return tx.RestoreFile(file, "")
+	})
 }
 
 //RenameFile in storage
@@ -118,107 +272,108 @@ func (lc *Storage) RenameFile(clientPath, newName string) (string, error) {
 	}
 
 	oldPsPath := lc.GetPhysicalWorkingPath(clientPath)
-	newPsPath := lc.GetPhysicalWorkingPath(newName)
-
-	if fileExists(newPsPath) {
-		return "", ErrFileExisted
-	}
-	if err := os.MkdirAll(filepath.Dir(newPsPath), os.ModePerm); err != nil {
-		return "", err
-	}
-	err = os.Rename(oldPsPath, newPsPath)
+	newPsPath, newName, err := lc.correctFileName(newName)
 	if err != nil {
 		return "", err
 	}
-
-	// Save rename action to database.
-	// If failed to save action, rename to the origin one
-	if err := lc.db.RenameFile(file, newName); err != nil {
-		os.Rename(newPsPath, oldPsPath)
+	intent := &database.Intent{Action: database.RenameAction, Path: clientPath, NewPath: newName}
+	err = lc.run(intent, func() error {
+		if err := os.MkdirAll(filepath.Dir(newPsPath), os.ModePerm); err != nil {
+			return err
+		}
+		// the new name is taken by a link, so a file created meanwhile is never overwritten
+		if err := lc.link(intent, oldPsPath, newPsPath); err != nil {
+			return err
+		}
+		if err := os.Remove(oldPsPath); err != nil {
+			return err
+		}
+		return syncDir(filepath.Dir(oldPsPath))
+	}, func(tx *database.DB) error {
+		return tx.RenameFile(file, newName)
+	})
+	if err != nil {
+		file.Fullname = clientPath
 		return "", err
 	}
 	return newName, nil
 }
 
-// RollbackRenameFile will try to rollback of action renamefile
-func (lc *Storage) RollbackRenameFile(path, newName string) (err error) {
-	var dbFile *database.File
-	dbFile, err = lc.db.GetFileByName(newName)
+// CopyFile to newName, the copy shares content with the source when possible
+func (lc *Storage) CopyFile(path, newName string) (*database.File, error) {
+	source, err := lc.db.GetFileByName(path)
 	if err != nil {
-		return
-	}
-
-	err = lc.db.RenameFile(dbFile, path)
-	return
-}
-
-func (lc *Storage) physicalDeleteFile(fileName string) (*database.File, string, string, string, string, error) {
-	clientFileDir := filepath.Dir(fileName)
-
-	file, err := lc.db.GetFileByName(fileName)
-	if err != nil {
-		return nil, "", "", "", "", err
+		return nil, err
 	}
-
-	// Copy the file to history zone
-	psPath := lc.GetPhysicalWorkingPath(fileName)
-	hsPath := lc.GetPhysicalHistoricalPath(fileName)
-	dst, err := copyFile(psPath, hsPath, true)
+	serverPath, clientPath, err := lc.correctFileName(newName)
 	if err != nil {
-		return nil, "", dst, psPath, hsPath, err
+		return nil, err
 	}
-
-	// Delete the actual file in working zone
-	err = os.Remove(psPath)
-	if err != nil {
-		// If can not save the file, copy the from the history zone to working zone and remove the file in history zone
-		if _, cfErr := copyFile(hsPath, psPath, false); cfErr == nil {
-			os.Remove(dst)
+	fileModel := database.File{Fullname: clientPath}
+	fileModel.SetContent(source)
+	intent := &database.Intent{Action: database.CreateAction, Path: clientPath, TempPath: tempPath(clientPath)}
+	err = lc.run(intent, func() error {
+		if err := os.MkdirAll(filepath.Dir(serverPath), os.ModePerm); err != nil {
+			return err
+		}
+		if _, err := copyFile(lc.GetPhysicalWorkingPath(path), lc.GetPhysicalWorkingPath(intent.TempPath), false); err != nil {
+			return err
 		}
-		// Delete copyfile when removing the file is getting error
-		os.Remove(dst)
-		return nil, "", dst, psPath, hsPath, err
+		return lc.publish(intent)
+	}, func(tx *database.DB) error {
+		if err := acquireBlob(tx, &fileModel); err != nil {
+			return err
+		}
+		return tx.CreateFile(&fileModel)
+	})
+	if err != nil {
+		return nil, err
 	}
-	dstBase := filepath.Base(dst)
-	backupPath := filepath.Join(clientFileDir, dstBase)
-	return file, backupPath, dst, psPath, hsPath, nil
-
+	return &fileModel, nil
 }
 
 // DeleteFile will copy the file to history zone, then remove the file in working zone
-// return the backup file and error if exists
+// return the backup path and error if exists
 func (lc *Storage) DeleteFile(fileName string) (string, error) {
-	file, backupPath, dst, psPath, hsPath, err := lc.physicalDeleteFile(fileName)
-	if err == nil {
+	file, err := lc.db.GetFileByName(fileName)
+	if err != nil {
 		return "", err
 	}
-	err = lc.db.DeleteFile(file, backupPath)
-	// If can not save the file, copy the from the history zone to working zone and remove the file in history zone
-	if err != nil {
-		if _, cfErr := copyFile(hsPath, psPath, false); cfErr == nil {
-			os.Remove(dst)
+	intent := &database.Intent{
+		Action:   database.DeleteAction,
+		Path:     fileName,
+		TempPath: tempPath(fileName),
+	}
+	var dropped bool
+	err = lc.run(intent, func() error {
+		if err := lc.backup(intent); err != nil {
+			return err
+		}
+		if err := failpoint(intent.Action + ":backup"); err != nil {
+			return err
+		}
+		return os.Remove(lc.GetPhysicalWorkingPath(fileName))
+	}, func(tx *database.DB) (err error) {
+		if dropped, err = releaseBlob(tx, file.BlobID); err != nil {
+			return err
 		}
+		return tx.DeleteFile(file, intent.BackupPath)
+	})
+	if err != nil {
 		return "", err
 	}
-	return dst, nil
-}
-
-// RollbackDeleteFile will try to rollback action deletefile
-// Get the backup file and copy that file to working zone
-func (lc *Storage) RollbackDeleteFile(fileName, bkFile string) (err error) {
-	bkPath := filepath.Join(lc.HistoryDir, filepath.Base(bkFile))
-	path := filepath.Join(filepath.Base(fileName), lc.WorkingDir)
-	if _, err = copyFile(bkPath, path, false); err != nil {
-		return
+	if dropped {
+		lc.dropUnusedBlob(*file.BlobID, 0)
 	}
-	dbFile := database.File{Fullname: fileName}
-	err = lc.db.CreateFile(&dbFile)
-	return
+	return intent.BackupPath, nil
 }
 
 // GetImage from filename
 func (lc *Storage) GetImage(filename string) (image.Image, error) {
-	var path = filepath.Join(lc.WorkingDir, filename)
+	_, path, err := lc.lookupPath(filename)
+	if err != nil {
+		return nil, err
+	}
 	//Check if file extention is valid
 	var ext = filepath.Ext(path)
 	if !lc.IsValidExt(ext) {
@@ -232,14 +387,26 @@ func (lc *Storage) GetImage(filename string) (image.Image, error) {
 	return imageData, nil
 }
 
-// CreateMissingFiles files
+// CreateMissingFiles files, their metadata is read like in AddFile.
+// Files of operations in progress are skipped
 func (lc *Storage) CreateMissingFiles() {
+	intents, err := lc.db.GetIntents()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	pending := make(map[string]bool)
+	for _, intent := range intents {
+		pending[intent.Path] = true
+		pending[intent.NewPath] = true
+	}
 	filepath.Walk(lc.WorkingDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			log.Print(err)
 			return err
 		}
-		if info.IsDir() {
+		// skip directories and temporary files of unfinished operations
+		if info.IsDir() || isHidden(info.Name()) {
 			return nil
 		}
 
@@ -249,14 +416,66 @@ func (lc *Storage) CreateMissingFiles() {
 		}
 
 		// skip if this file exists in database
-		if _, err := lc.db.GetFileByName(localPath); err == nil {
+		if _, err := lc.db.GetFileByName(localPath); err == nil || pending[localPath] {
 			return nil
 		}
 
-		return lc.db.CreateFile(&database.File{Fullname: localPath})
+		file := database.File{Fullname: localPath}
+		meta, err := readMetadata(localPath, path)
+		if err != nil {
+			return err
+		}
+		meta.Apply(&file)
+		return lc.db.CreateFile(&file)
 	})
 }
 
+// ListFiles calls fn with client path of every file in working zone, temporary files are skipped
+func (lc *Storage) ListFiles(fn func(path string) error) error {
+	return filepath.Walk(lc.WorkingDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if isHidden(info.Name()) && path != lc.WorkingDir {
+			return skipHidden(info)
+		}
+		if info.IsDir() {
+			return nil
+		}
+		clientPath, err := filepath.Rel(lc.WorkingDir, path)
+		if err != nil {
+			return err
+		}
+		return fn(clientPath)
+	})
+}
+
+// Open a file in working zone for serving, directories are not listed
+func (lc *Storage) Open(name string) (http.File, error) {
+	clientPath, _, err := lc.lookupPath(name)
+	if err == ErrFileNameInvalid {
+		return nil, os.ErrPermission
+	}
+	if err != nil {
+		return nil, err
+	}
+	return openRegularFile(lc.WorkingDir, clientPath)
+}
+
+// OpenHistory opens a backup file in history zone
+func (lc *Storage) OpenHistory(backupPath string) (http.File, error) {
+	return openRegularFile(lc.HistoryDir, backupPath)
+}
+
+// PurgeHistory removes a backup file from history zone
+func (lc *Storage) PurgeHistory(backupPath string) error {
+	err := os.Remove(lc.GetPhysicalHistoricalPath(backupPath))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 // GetFilePath from filename
 func (lc *Storage) GetFilePath(filename string) string {
 	return filepath.Join(lc.WorkingDir, filename)
@@ -1,12 +1,16 @@
 package localstorage
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/jinzhu/gorm"
 	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
 	"github.com/thanhtuan260593/file-server/database"
+	"github.com/thanhtuan260593/file-server/storages"
 	"github.com/twinj/uuid"
 )
 
@@ -29,8 +33,10 @@ func reset() {
 	store = NewStorage(db)
 	store.WorkingDir = testImagesStorageFolder
 	store.HistoryDir = testImagesHistoryFolder
+	store.BlobDir = testImagesBlobFolder
 	RemoveContents(store.WorkingDir)
 	RemoveContents(store.HistoryDir)
+	RemoveContents(store.BlobDir)
 	loadExternalFile()
 }
 
@@ -92,8 +98,301 @@ func TestReplaceFile(t *testing.T) {
 	}
 }
 
+func TestRestoreFile(t *testing.T) {
+	t.Run("Replace file to restore", TestReplaceFile)
+	file, err := store.db.GetFileByName(addedFile.DestName)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	replaced, err := store.db.GetLastFileHistory(file.ID, database.ReplaceAction)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	restored, err := store.RestoreFile(replaced)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	original, _ := ioutil.ReadFile(filepath.Join(testImageSourceFolder, addedFile.DestName))
+	content, _ := ioutil.ReadFile(store.GetPhysicalWorkingPath(addedFile.DestName))
+	assert.Equal(t, original, content)
+	assert.Equal(t, int64(len(original)), restored.Size)
+
+	// Undelete from the backup of deletion
+	if _, err := store.DeleteFile(addedFile.DestName); err != nil {
+		t.Error(err)
+		return
+	}
+	deleted, err := store.db.GetLastFileHistory(file.ID, database.DeleteAction)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := store.RestoreFile(deleted); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := store.db.GetFileByName(addedFile.DestName); err != nil {
+		t.Error(err)
+	}
+	content, _ = ioutil.ReadFile(store.GetPhysicalWorkingPath(addedFile.DestName))
+	assert.Equal(t, original, content)
+}
+
+func TestPurgeHistory(t *testing.T) {
+	t.Run("Replace file to purge", TestReplaceFile)
+	path := filepath.Join(testImageSourceFolder, addedFile.DestName)
+	reader, err := os.Open(path)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer reader.Close()
+	newest, err := store.ReplaceFile(addedFile.DestName, reader)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	purged, err := storages.Purge(store.db, store, storages.RetentionPolicy{KeepVersions: 1})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.Equal(t, 1, purged)
+	assert.True(t, fileExists(store.GetPhysicalHistoricalPath(newest)))
+
+	file, _ := store.db.GetFileByName(addedFile.DestName)
+	histories, _ := store.db.GetFileHistories(file.ID)
+	if assert.Len(t, histories, 3) {
+		assert.False(t, histories[1].HasBackup())
+		assert.False(t, fileExists(store.GetPhysicalHistoricalPath(histories[1].BackupPath)))
+		assert.True(t, histories[2].HasBackup())
+	}
+	_, err = store.RestoreFile(&histories[1])
+	assert.Equal(t, ErrFileNotFound, err)
+}
+
+func TestFsck(t *testing.T) {
+	defer func() { store.ContentAddressable = false }()
+	for _, contentAddressable := range []bool{false, true} {
+		testFsck(t, contentAddressable)
+	}
+}
+
+func testFsck(t *testing.T, contentAddressable bool) {
+	reset()
+	store.ContentAddressable = contentAddressable
+	path := filepath.Join(testImageSourceFolder, addedFile.DestName)
+	var blobID *string
+	for _, name := range []string{"changed.jpg", "missing.jpg", "deleted.jpg", "legacy.jpg", "pending.jpg"} {
+		reader, err := os.Open(path)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		file, err := store.AddFile(reader, name)
+		reader.Close()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		blobID = file.BlobID
+	}
+	backupPath, err := store.DeleteFile("deleted.jpg")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	os.Remove(store.GetPhysicalHistoricalPath(backupPath))
+	os.Remove(store.GetPhysicalWorkingPath("missing.jpg"))
+	// the file is replaced, writing in place would change the blob shared with other files
+	os.Remove(store.GetPhysicalWorkingPath("changed.jpg"))
+	ioutil.WriteFile(store.GetPhysicalWorkingPath("changed.jpg"), []byte("changed"), 0644)
+	ioutil.WriteFile(store.GetPhysicalWorkingPath("untracked.txt"), []byte("untracked"), 0644)
+	store.db.Model(&database.File{}).Where("fullname = ?", "legacy.jpg").UpdateColumn("checksum", "")
+	// a delete in progress, its file is gone but its row is not deleted yet
+	intent := &database.Intent{Action: database.DeleteAction, Path: "pending.jpg"}
+	store.db.CreateIntent(intent)
+	defer store.db.DeleteIntent(intent)
+	os.Remove(store.GetPhysicalWorkingPath("pending.jpg"))
+
+	problems, err := storages.Fsck(store.db, store, false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	kinds := make(map[string]string)
+	for _, problem := range problems {
+		kinds[problem.Path] = problem.Kind
+		assert.False(t, problem.Fixed)
+	}
+	assert.Equal(t, map[string]string{
+		"changed.jpg":   storages.ProblemChecksumMismatch,
+		"missing.jpg":   storages.ProblemMissingFile,
+		"untracked.txt": storages.ProblemUntrackedFile,
+		"legacy.jpg":    storages.ProblemMissingChecksum,
+		backupPath:      storages.ProblemMissingBackup,
+	}, kinds)
+
+	if problems, err = storages.Fsck(store.db, store, true); err != nil {
+		t.Error(err)
+		return
+	}
+	assert.Len(t, problems, 5)
+	problems, err = storages.Fsck(store.db, store, false)
+	assert.Nil(t, err)
+	assert.Empty(t, problems)
+	_, err = store.db.GetFileByName("pending.jpg")
+	assert.Nil(t, err, "the row of an operation in progress is kept")
+
+	if !contentAddressable {
+		return
+	}
+	// legacy.jpg and pending.jpg keep the old blob, changed.jpg has its own blob
+	if blob, err := store.db.GetBlob(*blobID); assert.NoError(t, err) {
+		assert.Equal(t, 2, blob.RefCount)
+	}
+	changed, err := store.db.GetFileByName("changed.jpg")
+	if assert.NoError(t, err) && assert.NotNil(t, changed.BlobID) {
+		assert.NotEqual(t, *blobID, *changed.BlobID)
+		assert.Equal(t, changed.Checksum, *changed.BlobID)
+		if blob, err := store.db.GetBlob(*changed.BlobID); assert.NoError(t, err) {
+			assert.Equal(t, 1, blob.RefCount)
+		}
+	}
+}
+
+func TestFsckReleasesBlob(t *testing.T) {
+	reset()
+	store.ContentAddressable = true
+	defer func() { store.ContentAddressable = false }()
+	reader, err := os.Open(filepath.Join(testImageSourceFolder, addedFile.DestName))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	file, err := store.AddFile(reader, "blob.jpg")
+	reader.Close()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	os.Remove(store.GetPhysicalWorkingPath("blob.jpg"))
+	if _, err := storages.Fsck(store.db, store, true); err != nil {
+		t.Error(err)
+		return
+	}
+	_, err = store.db.GetBlob(*file.BlobID)
+	assert.True(t, gorm.IsRecordNotFoundError(err), "the blob is released")
+	_, err = os.Stat(store.blobPath(*file.BlobID))
+	assert.True(t, os.IsNotExist(err), "the blob file is removed")
+}
+
+func TestNamePolicy(t *testing.T) {
+	t.Run("Add file to rename", TestAddFile)
+	outside, err := ioutil.TempDir("", "outside")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(outside)
+	ioutil.WriteFile(filepath.Join(outside, "secret.png"), []byte("secret"), 0644)
+	if err := os.Symlink(outside, store.GetPhysicalWorkingPath("escape")); err != nil {
+		t.Error(err)
+		return
+	}
+	os.Symlink(filepath.Join(outside, "missing.png"), store.GetPhysicalWorkingPath("dangling.png"))
+
+	for _, name := range []string{"../escaped.png", "/abs.png", "escape/added.png", "dangling.png", ".hidden.png"} {
+		reader, _ := os.Open(filepath.Join(testImageSourceFolder, addedFile.DestName))
+		_, err := store.AddFile(reader, name)
+		reader.Close()
+		assert.Equal(t, ErrFileNameInvalid, err, name)
+	}
+	for _, name := range []string{"added.png", "missing.png"} {
+		_, err := os.Lstat(filepath.Join(outside, name))
+		assert.True(t, os.IsNotExist(err), name)
+	}
+
+	for _, name := range []string{"/escape/secret.png", "/../images/escape/secret.png"} {
+		_, err := store.Open(name)
+		assert.Error(t, err, name)
+		_, err = store.GetImage(name)
+		assert.Equal(t, ErrFileNameInvalid, err, name)
+	}
+
+	_, err = store.RenameFile(addedFile.DestName, "escape/renamed.png")
+	assert.Equal(t, ErrFileNameInvalid, err)
+	_, err = store.RenameFile(addedFile.DestName, "a/../../renamed.png")
+	assert.Equal(t, ErrFileNameInvalid, err)
+}
+
+func TestCopyFile(t *testing.T) {
+	t.Run("Create file to copy", TestAddFile)
+	copied, err := store.CopyFile(addedFile.DestName, "copied/"+addedFile.DestName)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !fileExists(store.GetPhysicalWorkingPath(copied.Fullname)) {
+		t.Errorf("%v is not copied", copied.Fullname)
+	}
+}
+
+func TestContentAddressableFile(t *testing.T) {
+	reset()
+	store.ContentAddressable = true
+	defer func() { store.ContentAddressable = false }()
+	path := filepath.Join(testImageSourceFolder, addedFile.DestName)
+	var blobID string
+	for _, name := range []string{"first.jpg", "second.jpg"} {
+		reader, err := os.Open(path)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		file, err := store.AddFile(reader, name)
+		reader.Close()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		blobID = *file.BlobID
+	}
+	copied, err := store.CopyFile("first.jpg", "third.jpg")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.Equal(t, blobID, *copied.BlobID)
+	blob, err := store.db.GetBlob(blobID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.Equal(t, 3, blob.RefCount)
+
+	for _, name := range []string{"first.jpg", "second.jpg", "third.jpg"} {
+		assert.True(t, fileExists(store.blobPath(blobID)))
+		if _, err := store.DeleteFile(name); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	assert.False(t, fileExists(store.blobPath(blobID)))
+}
+
 func TestCreateMissingFiles(t *testing.T) {
 	reset()
 	store.WorkingDir = testImageSourceFolder
 	store.CreateMissingFiles()
+	file, err := store.db.GetFileByName(addedFile.DestName)
+	if assert.NoError(t, err) {
+		assert.NotZero(t, file.Size)
+		assert.NotZero(t, file.Width)
+		assert.NotEmpty(t, file.ContentType)
+		assert.NotEmpty(t, file.Checksum)
+	}
 }
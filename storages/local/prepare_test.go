@@ -12,6 +12,7 @@ var testFolder = "../_test"
 var testImageSourceFolder = "../../_test/source"
 var testImagesStorageFolder = "../../_test/images"
 var testImagesHistoryFolder = "../../_test/_history"
+var testImagesBlobFolder = "../../_test/_blobs"
 
 type URLImage struct {
 	DestName string
@@ -75,8 +76,10 @@ func downloadTestFiles() error {
 	err = os.MkdirAll(testImageSourceFolder, os.ModePerm)
 	err = os.MkdirAll(testImagesStorageFolder, os.ModePerm)
 	err = os.MkdirAll(testImagesHistoryFolder, os.ModePerm)
+	err = os.MkdirAll(testImagesBlobFolder, os.ModePerm)
 	RemoveContents(testImagesStorageFolder)
 	RemoveContents(testImagesHistoryFolder)
+	RemoveContents(testImagesBlobFolder)
 	if err != nil {
 		fmt.Println(err)
 		return err
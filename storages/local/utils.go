@@ -7,9 +7,12 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/thanhtuan260593/file-server/storages"
 )
 
 //GetPhysicalWorkingPath from client path
@@ -24,6 +27,111 @@ func (lc *Storage) GetPhysicalHistoricalPath(clientPath string) (rs string) {
 	return
 }
 
+// readMetadata of a physical file, name is the client path used to detect content type
+func readMetadata(name, serverPath string) (*storages.Metadata, error) {
+	f, err := os.Open(serverPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return storages.ReadMetadata(name, f)
+}
+
+// writeFile creates serverPath with content of reader, the content is synced to disk
+func writeFile(serverPath string, reader io.Reader) (err error) {
+	out, err := os.Create(serverPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	if _, err = io.Copy(out, reader); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+// renameFile moves src to dst at once, replacing dst if it exists.
+// The directories are synced, so the move survives a crash
+func renameFile(src, dst string) error {
+	if err := os.Rename(src, dst); err != nil {
+		return err
+	}
+	if filepath.Dir(src) != filepath.Dir(dst) {
+		if err := syncDir(filepath.Dir(src)); err != nil {
+			return err
+		}
+	}
+	return syncDir(filepath.Dir(dst))
+}
+
+// linkOrCopy creates dst with the content of src, sharing it by a hard link when possible.
+// dst is never overwritten, ErrFileExisted is returned if it exists
+func linkOrCopy(src, dst string) (err error) {
+	err = os.Link(src, dst)
+	if err == nil {
+		return nil
+	}
+	if os.IsExist(err) {
+		return ErrFileExisted
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return ErrFileExisted
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	if _, err = io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+// sameFile return true if both paths exist and are links of the same file
+func sameFile(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(ai, bi)
+}
+
+// syncDir flushes the entries of a directory to disk
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// removeIfExists removes a file, removing a missing file is not an error
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 func tryGetNotExistFilename(path string) (string, error) {
 	ext := filepath.Ext(path)
 	name := strings.TrimSuffix(path, ext)
@@ -37,14 +145,90 @@ func tryGetNotExistFilename(path string) (string, error) {
 	return "", ErrFileExisted
 }
 
+// correctFileName checks source by the name policy of storage,
+// return the server path and client path of a new file
 func (lc *Storage) correctFileName(source string) (string, string, error) {
-	//TODO: change file name to a valid one
-	clientPath := filepath.Clean(source)
-	serverPath := filepath.Join(lc.WorkingDir, clientPath)
-	if !fileExists(serverPath) {
-		return serverPath, clientPath, nil
+	clientPath, err := lc.Names.Clean(source)
+	if err != nil {
+		return "", "", err
+	}
+	serverPath, err := lc.workingPath(clientPath)
+	if err != nil {
+		return "", "", err
+	}
+	if fileExists(serverPath) {
+		return "", "", ErrFileExisted
+	}
+	return serverPath, clientPath, nil
+}
+
+// lookupPath return the client path and server path of an existed file from a request path
+func (lc *Storage) lookupPath(name string) (string, string, error) {
+	clientPath, err := storages.CleanPath(name)
+	if err != nil {
+		return "", "", err
+	}
+	serverPath, err := lc.workingPath(clientPath)
+	if err != nil {
+		return "", "", err
+	}
+	return clientPath, serverPath, nil
+}
+
+// workingPath return the server path of a client path.
+// ErrFileNameInvalid is returned if a symbolic link on the path leads out of working zone
+func (lc *Storage) workingPath(clientPath string) (string, error) {
+	serverPath := lc.GetPhysicalWorkingPath(clientPath)
+	root, err := filepath.EvalSymlinks(lc.WorkingDir)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := evalExistingSymlinks(serverPath)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrFileNameInvalid
+	}
+	return serverPath, nil
+}
+
+// evalExistingSymlinks follows the symbolic links in the existing part of path.
+// A dangling link is reported as ErrFileNameInvalid, since writing to it would create its target
+func evalExistingSymlinks(path string) (string, error) {
+	rest := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return "", ErrFileNameInvalid
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(path, rest), nil
+		}
+		rest = filepath.Join(filepath.Base(path), rest)
+		path = parent
 	}
-	return "", "", ErrFileExisted
+}
+
+// isHidden return true for names reserved to storage, such as temporary files
+func isHidden(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+// skipHidden skips a hidden file or directory in a walk
+func skipHidden(info os.FileInfo) error {
+	if info.IsDir() {
+		return filepath.SkipDir
+	}
+	return nil
 }
 
 func fileExists(filename string) bool {
@@ -55,6 +239,20 @@ func fileExists(filename string) bool {
 	return !info.IsDir()
 }
 
+// openRegularFile opens name in dir, directories are reported as not existed
+func openRegularFile(dir, name string) (http.File, error) {
+	f, err := http.Dir(dir).Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return nil, os.ErrNotExist
+	}
+	return f, nil
+}
+
 func getImageFromPath(filepath string) (image.Image, error) {
 	if !fileExists(filepath) {
 		return nil, ErrFileNotFound
@@ -68,10 +266,10 @@ func getImageFromPath(filepath string) (image.Image, error) {
 	return imageData, nil
 }
 
-//IsValidExt return true if file extension is a valid extension
+//IsValidExt return true if file extension is a valid extension, extensions are case insensitive
 func (lc *Storage) IsValidExt(ext string) bool {
 	for _, item := range lc.ValidExts {
-		if item == ext {
+		if strings.EqualFold(item, ext) {
 			return true
 		}
 	}
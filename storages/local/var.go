@@ -1,6 +1,9 @@
 package localstorage
 
-import "errors"
+import "github.com/thanhtuan260593/file-server/storages"
+
+//DriverName of local storage
+var DriverName = "local"
 
 //DefaultWorkingDir global value
 var DefaultWorkingDir string = "/files/images"
@@ -8,25 +11,30 @@ var DefaultWorkingDir string = "/files/images"
 //DefaultHistoryDir global value
 var DefaultHistoryDir string = "/files/_history"
 
+//DefaultBlobDir global value
+var DefaultBlobDir string = "/files/_blobs"
+
 //ServerImageURL value
 var ServerImageURL string
 
 //Expected errors
 var (
-	ErrFileNotFound   = errors.New("file-not-found")
-	ErrFileNotRead    = errors.New("file-not-read")
-	ErrFileExtInvalid = errors.New("file-ext-invalid")
-	ErrFileExisted    = errors.New("file-existed")
+	ErrFileNotFound    = storages.ErrFileNotFound
+	ErrFileNotRead     = storages.ErrFileNotRead
+	ErrFileExtInvalid  = storages.ErrFileExtInvalid
+	ErrFileExisted     = storages.ErrFileExisted
+	ErrFileNameInvalid = storages.ErrFileNameInvalid
 )
 
-//ValidNameChars is collection of accepted characters
-var ValidNameChars = "qwertyuiopasdfghjklzxcvbnmQWERTYUIOPASDFGHJKLZXCVBNM-"
-
 //MaxDuplicateFile value
 var MaxDuplicateFile = 2020
 
-//PngExt, SvgExt is extensions
+//PngExt, SvgExt, JpgExt, JpegExt, GifExt, WebpExt is extensions
 var (
-	PngExt = ".png"
-	SvgExt = ".svg"
+	PngExt  = ".png"
+	SvgExt  = ".svg"
+	JpgExt  = ".jpg"
+	JpegExt = ".jpeg"
+	GifExt  = ".gif"
+	WebpExt = ".webp"
 )
@@ -0,0 +1,275 @@
+package localstorage
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/thanhtuan260593/file-server/database"
+)
+
+// DefaultWatchDelay is how long a changed path must stay quiet before the watcher syncs it
+var DefaultWatchDelay = 500 * time.Millisecond
+
+// writeLog remembers the paths written by the server, so the watcher ignores their events
+type writeLog struct {
+	mu      sync.Mutex
+	running map[string]int
+	done    map[string]time.Time
+}
+
+// begin marks paths as being written
+func (w *writeLog) begin(paths ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.running == nil {
+		w.running = make(map[string]int)
+	}
+	for _, path := range paths {
+		w.running[path]++
+	}
+}
+
+// end marks paths as written now
+func (w *writeLog) end(paths ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.done == nil {
+		w.done = make(map[string]time.Time)
+	}
+	now := time.Now()
+	for _, path := range paths {
+		if w.running[path]--; w.running[path] <= 0 {
+			delete(w.running, path)
+		}
+		w.done[path] = now
+	}
+}
+
+// written return true if path is being written, or an event seen at changed may come from a write ended within delay
+func (w *writeLog) written(path string, changed time.Time, delay time.Duration) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// forget writes which can not be the source of new events
+	for p, at := range w.done {
+		if time.Since(at) > 10*delay {
+			delete(w.done, p)
+		}
+	}
+	if w.running[path] > 0 {
+		return true
+	}
+	at, ok := w.done[path]
+	return ok && !changed.After(at.Add(delay))
+}
+
+// Watch keeps database in sync with files created, renamed, changed and removed in working zone outside the server
+// until ctx is done. It returns at once if WatchChanges is false.
+// A changed path is synced after it stays quiet for WatchDelay, paths written by the server are ignored.
+func (lc *Storage) Watch(ctx context.Context) error {
+	if !lc.WatchChanges {
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := lc.watchDir(watcher, lc.WorkingDir, nil); err != nil {
+		return err
+	}
+	delay := lc.WatchDelay
+	if delay <= 0 {
+		delay = DefaultWatchDelay
+	}
+	ticker := time.NewTicker(delay / 2)
+	defer ticker.Stop()
+
+	// pending holds the time of the last event of each changed client path
+	pending := make(map[string]time.Time)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			lc.watchEvent(watcher, event, pending)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("Watcher: %v", err)
+		case now := <-ticker.C:
+			var due []string
+			for path, changed := range pending {
+				if now.Sub(changed) < delay {
+					continue
+				}
+				delete(pending, path)
+				if !lc.writes.written(path, changed, delay) {
+					due = append(due, path)
+				}
+			}
+			if len(due) > 0 {
+				if err := lc.syncPaths(due); err != nil {
+					log.Printf("Watcher: %v", err)
+				}
+			}
+		}
+	}
+}
+
+// watchEvent adds the client path changed by event to pending,
+// new directories are watched and their files are added
+func (lc *Storage) watchEvent(watcher *fsnotify.Watcher, event fsnotify.Event, pending map[string]time.Time) {
+	if event.Op == fsnotify.Chmod || isHidden(filepath.Base(event.Name)) {
+		return
+	}
+	clientPath, err := filepath.Rel(lc.WorkingDir, event.Name)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	if event.Op&fsnotify.Create != 0 {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			// files may be moved in before the directory is watched
+			if err := lc.watchDir(watcher, event.Name, func(path string) {
+				pending[path] = now
+			}); err != nil {
+				log.Printf("Watcher: %v", err)
+			}
+			return
+		}
+	}
+	pending[clientPath] = now
+}
+
+// watchDir watches dir and its sub directories, found is called with client path of every file in them
+func (lc *Storage) watchDir(watcher *fsnotify.Watcher, dir string, found func(path string)) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if isHidden(info.Name()) && path != dir {
+			return skipHidden(info)
+		}
+		if info.IsDir() {
+			return watcher.Add(path)
+		}
+		if found != nil {
+			clientPath, err := filepath.Rel(lc.WorkingDir, path)
+			if err != nil {
+				return err
+			}
+			found(clientPath)
+		}
+		return nil
+	})
+}
+
+// syncPaths updates database by the current state of changed client paths.
+// A file disappeared from one path and appeared at another with the same content is renamed,
+// so it keeps its tags and history.
+func (lc *Storage) syncPaths(paths []string) error {
+	var appeared, disappeared []*database.File
+	for _, path := range paths {
+		file, err := lc.db.GetFileByName(path)
+		if err != nil && err != database.ErrNotFound {
+			return err
+		}
+		serverPath := lc.GetPhysicalWorkingPath(path)
+		info, err := os.Stat(serverPath)
+		switch {
+		case os.IsNotExist(err):
+			if file != nil {
+				disappeared = append(disappeared, file)
+				continue
+			}
+			// a removed directory takes its files with it
+			files, err := lc.db.GetFilesUnder(path)
+			if err != nil {
+				return err
+			}
+			for i := range files {
+				disappeared = append(disappeared, &files[i])
+			}
+		case err != nil:
+			return err
+		case info.IsDir():
+			continue
+		default:
+			meta, err := readMetadata(path, serverPath)
+			if err != nil {
+				log.Printf("Watcher: can not read %s: %v", path, err)
+				continue
+			}
+			if file == nil {
+				file = &database.File{Fullname: path}
+				meta.Apply(file)
+				appeared = append(appeared, file)
+			} else if meta.Checksum != file.Checksum {
+				meta.Apply(file)
+				if err := lc.db.UpdateFileContent(file); err != nil {
+					return err
+				}
+				log.Printf("Watcher: updated %s", path)
+			}
+		}
+	}
+
+	for _, file := range appeared {
+		if i := indexOfChecksum(disappeared, file.Checksum); i >= 0 {
+			moved := disappeared[i]
+			disappeared = append(disappeared[:i], disappeared[i+1:]...)
+			oldName := moved.Fullname
+			if err := lc.db.RenameFile(moved, file.Fullname); err != nil {
+				return err
+			}
+			log.Printf("Watcher: renamed %s to %s", oldName, file.Fullname)
+			continue
+		}
+		if err := lc.db.CreateFile(file); err != nil {
+			return err
+		}
+		log.Printf("Watcher: tracked %s", file.Fullname)
+	}
+	for _, file := range disappeared {
+		if err := lc.untrackFile(file); err != nil {
+			return err
+		}
+		log.Printf("Watcher: untracked %s", file.Fullname)
+	}
+	return nil
+}
+
+// untrackFile deletes a file removed outside the server, its blob is dropped when no other file uses it
+func (lc *Storage) untrackFile(file *database.File) error {
+	var dropped bool
+	if err := lc.db.Atomic(func(tx *database.DB) (err error) {
+		if dropped, err = releaseBlob(tx, file.BlobID); err != nil {
+			return err
+		}
+		return tx.DeleteFile(file, "")
+	}); err != nil {
+		return err
+	}
+	if dropped {
+		return lc.dropUnusedBlob(*file.BlobID, 0)
+	}
+	return nil
+}
+
+func indexOfChecksum(files []*database.File, checksum string) int {
+	for i, file := range files {
+		if file.Checksum == checksum {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,77 @@
+package localstorage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thanhtuan260593/file-server/database"
+)
+
+func TestWatch(t *testing.T) {
+	reset()
+	store.WatchChanges = true
+	store.WatchDelay = 50 * time.Millisecond
+	defer func() { store.WatchChanges = false }()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go store.Watch(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	tracked := func(path string) func() bool {
+		return func() bool {
+			_, err := store.db.GetFileByName(path)
+			return err == nil
+		}
+	}
+	untracked := func(path string) func() bool {
+		return func() bool {
+			_, err := store.db.GetFileByName(path)
+			return err == database.ErrNotFound
+		}
+	}
+
+	// A file dropped into working zone is tracked
+	content, _ := ioutil.ReadFile(filepath.Join(testImageSourceFolder, addedFile.DestName))
+	ioutil.WriteFile(store.GetPhysicalWorkingPath("dropped.png"), content, 0644)
+	if !assert.Eventually(t, tracked("dropped.png"), 2*time.Second, 10*time.Millisecond) {
+		return
+	}
+	dropped, _ := store.db.GetFileByName("dropped.png")
+
+	// A moved file keeps its row
+	os.MkdirAll(store.GetPhysicalWorkingPath("moved"), os.ModePerm)
+	os.Rename(store.GetPhysicalWorkingPath("dropped.png"), store.GetPhysicalWorkingPath("moved/dropped.png"))
+	if assert.Eventually(t, tracked("moved/dropped.png"), 2*time.Second, 10*time.Millisecond) {
+		moved, _ := store.db.GetFileByName("moved/dropped.png")
+		assert.Equal(t, dropped.ID, moved.ID)
+		assert.True(t, untracked("dropped.png")())
+	}
+
+	// Files of a removed directory are untracked
+	os.RemoveAll(store.GetPhysicalWorkingPath("moved"))
+	assert.Eventually(t, untracked("moved/dropped.png"), 2*time.Second, 10*time.Millisecond)
+
+	// Files written by the server are left to the server
+	reader, _ := os.Open(filepath.Join(testImageSourceFolder, addedFile.DestName))
+	defer reader.Close()
+	if _, err := store.AddFile(reader, "added.png"); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := store.RenameFile("added.png", "renamed.png"); err != nil {
+		t.Error(err)
+		return
+	}
+	time.Sleep(200 * time.Millisecond)
+	renamed, err := store.db.GetFileByName("renamed.png")
+	if assert.NoError(t, err) {
+		histories, _ := store.db.GetFileHistories(renamed.ID)
+		assert.Len(t, histories, 2)
+	}
+	assert.True(t, untracked("added.png")())
+}
@@ -0,0 +1,123 @@
+package storages
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	// register decoders for reading dimensions
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+
+	"github.com/thanhtuan260593/file-server/database"
+)
+
+// sniffLen is the number of bytes used by http.DetectContentType
+const sniffLen = 512
+
+// Metadata describes the content of a file
+type Metadata struct {
+	Size        int64
+	ContentType string
+	Width       int
+	Height      int
+	ColorModel  string
+	Checksum    string
+}
+
+type counter int64
+
+func (c *counter) Write(p []byte) (int, error) {
+	*c += counter(len(p))
+	return len(p), nil
+}
+
+// ReadMetadata consumes reader and extracts metadata of its content.
+// Dimensions and color model are left empty if the content is not a decodable image.
+func ReadMetadata(name string, reader io.Reader) (*Metadata, error) {
+	var size counter
+	hash := sha256.New()
+	tee := io.TeeReader(reader, io.MultiWriter(hash, &size))
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(tee, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	meta := Metadata{}
+	meta.ContentType = detectContentType(name, head)
+	if config, _, err := image.DecodeConfig(io.MultiReader(bytes.NewReader(head), tee)); err == nil {
+		meta.Width = config.Width
+		meta.Height = config.Height
+		meta.ColorModel = colorModelName(config.ColorModel)
+	}
+	if _, err := io.Copy(ioutil.Discard, tee); err != nil {
+		return nil, err
+	}
+	meta.Size = int64(size)
+	meta.Checksum = hex.EncodeToString(hash.Sum(nil))
+	return &meta, nil
+}
+
+// Apply metadata to a file model
+func (meta *Metadata) Apply(file *database.File) {
+	file.Size = meta.Size
+	file.ContentType = meta.ContentType
+	file.Width = meta.Width
+	file.Height = meta.Height
+	file.ColorModel = meta.ColorModel
+	file.Checksum = meta.Checksum
+}
+
+func detectContentType(name string, head []byte) string {
+	contentType := http.DetectContentType(head)
+	// svg is sniffed as text
+	if strings.EqualFold(filepath.Ext(name), ".svg") &&
+		(strings.HasPrefix(contentType, "text/xml") || strings.HasPrefix(contentType, "text/plain")) {
+		return "image/svg+xml"
+	}
+	return contentType
+}
+
+func colorModelName(model color.Model) string {
+	if _, ok := model.(color.Palette); ok {
+		return "paletted"
+	}
+	switch model {
+	case color.RGBAModel:
+		return "rgba"
+	case color.RGBA64Model:
+		return "rgba64"
+	case color.NRGBAModel:
+		return "nrgba"
+	case color.NRGBA64Model:
+		return "nrgba64"
+	case color.AlphaModel:
+		return "alpha"
+	case color.Alpha16Model:
+		return "alpha16"
+	case color.GrayModel:
+		return "gray"
+	case color.Gray16Model:
+		return "gray16"
+	case color.CMYKModel:
+		return "cmyk"
+	case color.YCbCrModel:
+		return "ycbcr"
+	case color.NYCbCrAModel:
+		return "nycbcra"
+	}
+	return "unknown"
+}
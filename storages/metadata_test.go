@@ -0,0 +1,65 @@
+package storages
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thanhtuan260593/file-server/database"
+)
+
+func TestReadPngMetadata(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 7, 5))
+	img.Set(1, 1, color.White)
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, img); err != nil {
+		t.Error(err)
+		return
+	}
+	data := buffer.Bytes()
+	meta, err := ReadMetadata("gray.png", bytes.NewReader(data))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	sum := sha256.Sum256(data)
+	assert.Equal(t, int64(len(data)), meta.Size)
+	assert.Equal(t, "image/png", meta.ContentType)
+	assert.Equal(t, 7, meta.Width)
+	assert.Equal(t, 5, meta.Height)
+	assert.Equal(t, "gray", meta.ColorModel)
+	assert.Equal(t, hex.EncodeToString(sum[:]), meta.Checksum)
+
+	var file database.File
+	meta.Apply(&file)
+	assert.Equal(t, meta.Checksum, file.Checksum)
+	assert.Equal(t, 7, file.Width)
+}
+
+func TestReadSvgMetadata(t *testing.T) {
+	svg := `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" width="10" height="10"></svg>`
+	meta, err := ReadMetadata("icon.svg", strings.NewReader(svg))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.Equal(t, "image/svg+xml", meta.ContentType)
+	assert.Equal(t, int64(len(svg)), meta.Size)
+	assert.Equal(t, 0, meta.Width)
+}
+
+func TestReadEmptyMetadata(t *testing.T) {
+	meta, err := ReadMetadata("empty.png", strings.NewReader(""))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.Equal(t, int64(0), meta.Size)
+	assert.Equal(t, "", meta.ColorModel)
+}
@@ -0,0 +1,161 @@
+package storages
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrFileNameInvalid is returned for a path which can not be used as a client path
+var ErrFileNameInvalid = errors.New("file-name-invalid")
+
+// ValidNameChars is collection of characters kept by slugify mode, other characters become dashes
+var ValidNameChars = "qwertyuiopasdfghjklzxcvbnmQWERTYUIOPASDFGHJKLZXCVBNM0123456789-_"
+
+// MaxNameLength of each segment of a client path, in bytes
+var MaxNameLength = 255
+
+// reservedChars can not be used in names on some file systems
+const reservedChars = `<>:"|?*\`
+
+// reservedNames are device names on Windows, with or without extension
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// NamePolicy checks names given to new files
+type NamePolicy struct {
+	// Slugify rewrites each segment of a name to lower case letters, digits, dashes and underscores
+	Slugify bool
+}
+
+// NewNamePolicy read IMAGE_SLUGIFY_NAMES from os enviroment
+func NewNamePolicy() NamePolicy {
+	var policy NamePolicy
+	if v, err := strconv.ParseBool(os.Getenv("IMAGE_SLUGIFY_NAMES")); err == nil {
+		policy.Slugify = v
+	}
+	return policy
+}
+
+// Clean return the client path of a new file named name.
+// The name is normalized to NFC, empty and "." segments are dropped.
+// Absolute names, ".." segments, hidden and reserved names are rejected with ErrFileNameInvalid
+func (p NamePolicy) Clean(name string) (string, error) {
+	if !utf8.ValidString(name) || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") {
+		return "", ErrFileNameInvalid
+	}
+	var segments []string
+	for _, segment := range strings.Split(norm.NFC.String(name), "/") {
+		if segment == "" || segment == "." {
+			continue
+		}
+		if p.Slugify {
+			segment = slugify(segment)
+		}
+		if !validSegment(segment) {
+			return "", ErrFileNameInvalid
+		}
+		segments = append(segments, segment)
+	}
+	if len(segments) == 0 {
+		return "", ErrFileNameInvalid
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// CleanPath return the client path of an existed file from a request path, which may start with a slash.
+// The path is normalized to NFC like the names of new files, so NFD requests find their files.
+// Paths which can escape the root or reach hidden files are rejected with ErrFileNameInvalid
+func CleanPath(name string) (string, error) {
+	if !utf8.ValidString(name) || strings.ContainsAny(name, "\\\x00") {
+		return "", ErrFileNameInvalid
+	}
+	var segments []string
+	for _, segment := range strings.Split(strings.TrimPrefix(norm.NFC.String(name), "/"), "/") {
+		switch segment {
+		case "", ".":
+		case "..":
+			return "", ErrFileNameInvalid
+		default:
+			if strings.HasPrefix(segment, ".") {
+				return "", ErrFileNameInvalid
+			}
+			segments = append(segments, segment)
+		}
+	}
+	if len(segments) == 0 {
+		return "", ErrFileNameInvalid
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// validSegment return false for names which are reserved, hidden or can not be stored on common file systems
+func validSegment(segment string) bool {
+	if segment == "" || len(segment) > MaxNameLength || segment == ".." ||
+		strings.HasPrefix(segment, ".") || strings.HasSuffix(segment, ".") || strings.HasSuffix(segment, " ") ||
+		strings.ContainsAny(segment, reservedChars) {
+		return false
+	}
+	for _, r := range segment {
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+	base := strings.SplitN(segment, ".", 2)[0]
+	return !reservedNames[strings.ToUpper(strings.TrimSpace(base))]
+}
+
+// slugify keeps the extension of segment and replaces other characters out of ValidNameChars by dashes,
+// accents are removed first
+func slugify(segment string) string {
+	ext := ""
+	if i := strings.LastIndex(segment, "."); i > 0 {
+		ext = strings.ToLower(segment[i:])
+		segment = segment[:i]
+	}
+	var b strings.Builder
+	dash := false
+	for _, r := range norm.NFKD.String(segment) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if r < utf8.RuneSelf && strings.ContainsRune(ValidNameChars, r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		dash = true
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return b.String() + slugifyExt(ext)
+}
+
+// slugifyExt keeps only valid characters of an extension
+func slugifyExt(ext string) string {
+	if ext == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, r := range ext[1:] {
+		if r < utf8.RuneSelf && strings.ContainsRune(ValidNameChars, r) {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "." + b.String()
+}
@@ -0,0 +1,138 @@
+package storages
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestNamePolicyClean(t *testing.T) {
+	cases := []struct {
+		name     string
+		slugify  bool
+		expected string
+	}{
+		{"IMG_1001.JPG", false, "IMG_1001.JPG"},
+		{"a/./b//c.png", false, "a/b/c.png"},
+		{"café.png", false, "café.png"},
+		{"Ảnh Đẹp (1).PNG", true, "anh-ep-1.png"},
+		{"Hello World/Ca Phe.png", true, "hello-world/ca-phe.png"},
+		{"../a.png", false, ""},
+		{"a/../../b.png", false, ""},
+		{"/etc/passwd", false, ""},
+		{"a\\..\\b.png", false, ""},
+		{"a/", false, ""},
+		{"", false, ""},
+		{".tmp-1-a.png", false, ""},
+		{"a/.hidden/b.png", false, ""},
+		{"CON", false, ""},
+		{"dir/nul.txt", false, ""},
+		{"a?.png", false, ""},
+		{"a.png.", false, ""},
+		{"a\x00.png", false, ""},
+		{"a\n.png", false, ""},
+		{"\xff.png", false, ""},
+		{strings.Repeat("a", 256), false, ""},
+		{"???", true, ""},
+	}
+	for _, c := range cases {
+		cleaned, err := NamePolicy{Slugify: c.slugify}.Clean(c.name)
+		if c.expected == "" {
+			assert.Equal(t, ErrFileNameInvalid, err, c.name)
+			continue
+		}
+		if assert.NoError(t, err, c.name) {
+			assert.Equal(t, c.expected, cleaned, c.name)
+		}
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"/a/b.png", "a/b.png"},
+		{"a//./b.png", "a/b.png"},
+		{"/cafe\u0301.png", "caf\u00e9.png"},
+		{"/../a.png", ""},
+		{"/a/../../b.png", ""},
+		{"/a\\..\\b.png", ""},
+		{"/", ""},
+		{"/.tmp-1-a.png", ""},
+	}
+	for _, c := range cases {
+		cleaned, err := CleanPath(c.name)
+		if c.expected == "" {
+			assert.Equal(t, ErrFileNameInvalid, err, c.name)
+			continue
+		}
+		if assert.NoError(t, err, c.name) {
+			assert.Equal(t, c.expected, cleaned, c.name)
+		}
+	}
+}
+
+// pathString is a random path built from parts which are meaningful to path cleaning
+type pathString string
+
+var pathParts = []string{"a", "B.png", "/", "//", ".", "..", "\\", "\x00", "\n", " ", "?", "caf\u00e9", "cafe\u0301", "CON", ".tmp-", "\xff"}
+
+func (pathString) Generate(rand *rand.Rand, size int) reflect.Value {
+	var b strings.Builder
+	for i := rand.Intn(size + 1); i > 0; i-- {
+		b.WriteString(pathParts[rand.Intn(len(pathParts))])
+	}
+	return reflect.ValueOf(pathString(b.String()))
+}
+
+// contained return true if a cleaned path can not be used to leave the root
+func contained(cleaned string) bool {
+	if !utf8.ValidString(cleaned) || strings.HasPrefix(cleaned, "/") || strings.ContainsAny(cleaned, "\\\x00") {
+		return false
+	}
+	for _, segment := range strings.Split(cleaned, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNamePolicyCleanProperties(t *testing.T) {
+	property := func(name pathString, slugify bool) bool {
+		policy := NamePolicy{Slugify: slugify}
+		cleaned, err := policy.Clean(string(name))
+		if err != nil {
+			return true
+		}
+		again, err := policy.Clean(cleaned)
+		return contained(cleaned) && norm.NFC.IsNormalString(cleaned) && err == nil && again == cleaned
+	}
+	for _, seed := range []string{"a.png", "a/b/c.png", "../a", "/a", "a/./b", "CON.txt", "Ảnh Đẹp.PNG", "cafe\u0301"} {
+		assert.True(t, property(pathString(seed), false), seed)
+		assert.True(t, property(pathString(seed), true), seed)
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 10000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCleanPathProperties(t *testing.T) {
+	property := func(name pathString) bool {
+		cleaned, err := CleanPath(string(name))
+		return err != nil || contained(cleaned)
+	}
+	for _, seed := range []string{"/a.png", "/a/../b", "//a//b", "/.tmp-a", "\\..\\a"} {
+		assert.True(t, property(pathString(seed)), seed)
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 10000}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,113 @@
+package storages
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/thanhtuan260593/file-server/database"
+)
+
+// DefaultPurgeInterval is the period of purge worker
+var DefaultPurgeInterval = time.Hour
+
+// RetentionPolicy decides how long backups in history zone are kept.
+// Zero values keep backups forever
+type RetentionPolicy struct {
+	// KeepVersions is the number of newest backups kept for each file
+	KeepVersions int
+	// KeepDays is the number of days a backup is kept
+	KeepDays int
+	// Interval between two purges of the worker
+	Interval time.Duration
+}
+
+// NewRetentionPolicy read from IMAGE_HISTORY_KEEP_VERSIONS, IMAGE_HISTORY_KEEP_DAYS
+// and IMAGE_HISTORY_PURGE_INTERVAL
+func NewRetentionPolicy() RetentionPolicy {
+	policy := RetentionPolicy{Interval: DefaultPurgeInterval}
+	if v, err := strconv.Atoi(os.Getenv("IMAGE_HISTORY_KEEP_VERSIONS")); err == nil && v > 0 {
+		policy.KeepVersions = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("IMAGE_HISTORY_KEEP_DAYS")); err == nil && v > 0 {
+		policy.KeepDays = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("IMAGE_HISTORY_PURGE_INTERVAL")); err == nil && v > 0 {
+		policy.Interval = v
+	}
+	return policy
+}
+
+// Enabled return true if some backups may expire
+func (policy RetentionPolicy) Enabled() bool {
+	return policy.KeepVersions > 0 || policy.KeepDays > 0
+}
+
+// expiredBefore return the time backups created before are expired, zero if backups never expire by age
+func (policy RetentionPolicy) expiredBefore(now time.Time) time.Time {
+	if policy.KeepDays <= 0 {
+		return time.Time{}
+	}
+	return now.AddDate(0, 0, -policy.KeepDays)
+}
+
+// Purge removes backups expired by policy, return the number of purged backups
+func Purge(db *database.DB, backend Backend, policy RetentionPolicy) (int, error) {
+	if !policy.Enabled() {
+		return 0, nil
+	}
+	histories, err := db.GetExpiredHistories(policy.KeepVersions, policy.expiredBefore(time.Now()))
+	if err != nil {
+		return 0, err
+	}
+	return purgeHistories(db, backend, histories)
+}
+
+// EmptyTrash removes every backup of deleted files, return the number of purged backups
+func EmptyTrash(db *database.DB, backend Backend) (int, error) {
+	histories, err := db.GetTrashHistories()
+	if err != nil {
+		return 0, err
+	}
+	return purgeHistories(db, backend, histories)
+}
+
+// purgeHistories removes backups then marks their histories as purged
+func purgeHistories(db *database.DB, backend Backend, histories []database.FileHistory) (int, error) {
+	purged := 0
+	for i := range histories {
+		if err := backend.PurgeHistory(histories[i].BackupPath); err != nil {
+			return purged, err
+		}
+		if err := db.MarkHistoryPurged(&histories[i]); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// StartPurger runs Purge periodically until ctx is done
+func StartPurger(ctx context.Context, db *database.DB, backend Backend, policy RetentionPolicy) {
+	if !policy.Enabled() {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(policy.Interval)
+		defer ticker.Stop()
+		for {
+			if n, err := Purge(db, backend, policy); err != nil {
+				log.Printf("Can not purge history: %v", err)
+			} else if n > 0 {
+				log.Printf("Purged %d backups", n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,211 @@
+package s3storage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeS3 is a minimal in memory stand-in of a MinIO server.
+// It supports bucket creation, object listing and object put, copy, get, head and delete.
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]*fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+	modified    time.Time
+}
+
+type fakeCopyResult struct {
+	XMLName      xml.Name `xml:"CopyObjectResult"`
+	LastModified string   `xml:"LastModified"`
+	ETag         string   `xml:"ETag"`
+}
+
+type fakeListObject struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+}
+
+type fakeListResult struct {
+	XMLName     xml.Name         `xml:"ListBucketResult"`
+	Name        string           `xml:"Name"`
+	Prefix      string           `xml:"Prefix"`
+	KeyCount    int              `xml:"KeyCount"`
+	MaxKeys     int              `xml:"MaxKeys"`
+	IsTruncated bool             `xml:"IsTruncated"`
+	Contents    []fakeListObject `xml:"Contents"`
+}
+
+type fakeError struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{buckets: make(map[string]map[string]*fakeObject)}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	bucketName := parts[0]
+	bucket, bucketExisted := f.buckets[bucketName]
+	if len(parts) < 2 || parts[1] == "" {
+		switch r.Method {
+		case http.MethodHead:
+			if !bucketExisted {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case http.MethodPut:
+			if !bucketExisted {
+				f.buckets[bucketName] = make(map[string]*fakeObject)
+			}
+		case http.MethodGet:
+			if !bucketExisted {
+				writeFakeError(w, http.StatusNotFound, "NoSuchBucket")
+				return
+			}
+			f.listObjects(w, bucketName, bucket, r.URL.Query().Get("prefix"))
+		default:
+			writeFakeError(w, http.StatusNotImplemented, "NotImplemented")
+		}
+		return
+	}
+	if !bucketExisted {
+		writeFakeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	key := parts[1]
+	switch r.Method {
+	case http.MethodPut:
+		if source := r.Header.Get("X-Amz-Copy-Source"); source != "" {
+			f.copyObject(w, source, bucket, key)
+			return
+		}
+		data, err := readFakeBody(r)
+		if err != nil {
+			writeFakeError(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		bucket[key] = &fakeObject{data, r.Header.Get("Content-Type"), time.Now().UTC()}
+		w.Header().Set("ETag", fakeETag(data))
+	case http.MethodGet, http.MethodHead:
+		obj, ok := bucket[key]
+		if !ok {
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", fakeETag(obj.data))
+		w.Header().Set("Content-Type", obj.contentType)
+		http.ServeContent(w, r, key, obj.modified, bytes.NewReader(obj.data))
+	case http.MethodDelete:
+		delete(bucket, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeFakeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (f *fakeS3) copyObject(w http.ResponseWriter, source string, bucket map[string]*fakeObject, key string) {
+	source, _ = url.PathUnescape(source)
+	parts := strings.SplitN(strings.TrimPrefix(source, "/"), "/", 2)
+	srcBucket, ok := f.buckets[parts[0]]
+	if !ok || len(parts) < 2 {
+		writeFakeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	src, ok := srcBucket[parts[1]]
+	if !ok {
+		writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	data := append([]byte(nil), src.data...)
+	copied := &fakeObject{data, src.contentType, time.Now().UTC()}
+	bucket[key] = copied
+	result := fakeCopyResult{
+		LastModified: copied.modified.Format(time.RFC3339),
+		ETag:         fakeETag(data),
+	}
+	xml.NewEncoder(w).Encode(&result)
+}
+
+// listObjects writes every object having prefix in one page, sorted by key
+func (f *fakeS3) listObjects(w http.ResponseWriter, name string, bucket map[string]*fakeObject, prefix string) {
+	result := fakeListResult{Name: name, Prefix: prefix, MaxKeys: 1000}
+	for key, obj := range bucket {
+		if strings.HasPrefix(key, prefix) {
+			result.Contents = append(result.Contents, fakeListObject{
+				Key:          key,
+				LastModified: obj.modified.Format(time.RFC3339),
+				ETag:         fakeETag(obj.data),
+				Size:         int64(len(obj.data)),
+			})
+		}
+	}
+	sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+	result.KeyCount = len(result.Contents)
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(&result)
+}
+
+// readFakeBody decodes aws-chunked payload of streaming signature
+func readFakeBody(r *http.Request) ([]byte, error) {
+	if r.Header.Get("X-Amz-Content-Sha256") != "STREAMING-AWS4-HMAC-SHA256-PAYLOAD" {
+		return ioutil.ReadAll(r.Body)
+	}
+	var out bytes.Buffer
+	reader := bufio.NewReader(r.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		header := strings.SplitN(strings.TrimSpace(line), ";", 2)
+		size, err := strconv.ParseInt(header[0], 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return out.Bytes(), nil
+		}
+		if _, err := io.CopyN(&out, reader, size); err != nil {
+			return nil, err
+		}
+		if _, err := reader.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func fakeETag(data []byte) string {
+	return fmt.Sprintf(`"%x"`, md5.Sum(data))
+}
+
+func writeFakeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(&fakeError{Code: code, Message: code})
+}
@@ -0,0 +1,375 @@
+package s3storage
+
+import (
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/minio/minio-go/v6"
+	"github.com/thanhtuan260593/file-server/database"
+	"github.com/thanhtuan260593/file-server/storages"
+)
+
+func init() {
+	storages.Register(DriverName, func(db *database.DB) (storages.Backend, error) {
+		return NewStorage(db, NewConfig())
+	})
+}
+
+// Storage keeps files in a s3 compatible bucket
+type Storage struct {
+	Bucket        string
+	WorkingPrefix string
+	HistoryPrefix string
+	ValidExts     []string
+	// Names checks the names of new files
+	Names  storages.NamePolicy
+	client *minio.Client
+	db     *database.DB
+}
+
+// NewStorage return new S3 storage, the bucket is created if it does not exist
+func NewStorage(db *database.DB, config Config) (*Storage, error) {
+	client, err := minio.NewWithRegion(config.Endpoint, config.AccessKey, config.SecretKey, config.UseSSL, config.Region)
+	if err != nil {
+		return nil, err
+	}
+	exists, err := client.BucketExists(config.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := client.MakeBucket(config.Bucket, config.Region); err != nil {
+			return nil, err
+		}
+	}
+	var s3 = Storage{}
+	s3.db = db
+	s3.client = client
+	s3.Bucket = config.Bucket
+	s3.WorkingPrefix = config.WorkingPrefix
+	s3.HistoryPrefix = config.HistoryPrefix
+	s3.ValidExts = []string{PngExt, SvgExt, JpgExt, JpegExt, GifExt, WebpExt}
+	s3.Names = storages.NewNamePolicy()
+	return &s3, nil
+}
+
+func (s3 *Storage) physicalAddFile(reader io.Reader, fileName string) (string, error) {
+	clientPath, err := s3.Names.Clean(fileName)
+	if err != nil {
+		return "", err
+	}
+	existed, err := s3.exists(s3.workingKey(clientPath))
+	if err != nil {
+		return "", err
+	}
+	if existed {
+		return "", storages.ErrFileExisted
+	}
+	return clientPath, s3.putObject(clientPath, reader)
+}
+
+// AddFile upload file to the bucket
+func (s3 *Storage) AddFile(reader io.Reader, fileName string) (*database.File, error) {
+	clientPath, err := s3.physicalAddFile(reader, fileName)
+	if err != nil {
+		return nil, err
+	}
+	// Save new file to database if this file created successfully
+	fileModel := database.File{Fullname: clientPath}
+	meta, err := s3.readMetadata(clientPath)
+	if err == nil {
+		meta.Apply(&fileModel)
+		err = s3.db.CreateFile(&fileModel)
+	}
+	// If failed to save to database, delete the object
+	if err != nil {
+		s3.client.RemoveObject(s3.Bucket, s3.workingKey(clientPath))
+		return nil, err
+	}
+	return &fileModel, nil
+}
+
+// ReplaceFile in bucket, the old content is copied to history zone
+func (s3 *Storage) ReplaceFile(path string, reader io.Reader) (string, error) {
+	// Find file from database, if no file found, return error
+	file, err := s3.db.GetFileByName(path)
+	if err != nil {
+		return "", err
+	}
+	return s3.replaceFile(file, reader, s3.db.ReplaceFile)
+}
+
+// replaceFile uploads new content of a file after copying the old one to history zone.
+// save records the new content with the backup path in database
+func (s3 *Storage) replaceFile(file *database.File, reader io.Reader, save func(*database.File, string) error) (string, error) {
+	path := file.Fullname
+	backupPath, err := s3.copyToHistory(path)
+	if err != nil {
+		return "", err
+	}
+	// An object is replaced at once, so the working object is untouched if the upload failed
+	if err := s3.putObject(path, reader); err != nil {
+		s3.client.RemoveObject(s3.Bucket, s3.historyKey(backupPath))
+		return "", err
+	}
+
+	// Save metadata of new content, the old content is put back if it can not be recorded
+	meta, err := s3.readMetadata(path)
+	if err == nil {
+		meta.Apply(file)
+		err = save(file, backupPath)
+	}
+	if err != nil {
+		s3.restoreBackup(path, backupPath)
+		return "", err
+	}
+	return backupPath, nil
+}
+
+// restoreBackup copies a backup back to the working object then removes the backup.
+// The backup is kept if it can not be copied
+func (s3 *Storage) restoreBackup(path, backupPath string) {
+	if err := s3.copyObject(s3.historyKey(backupPath), s3.workingKey(path)); err != nil {
+		log.Printf("Can not restore %s from backup %s: %v", path, backupPath, err)
+		return
+	}
+	s3.client.RemoveObject(s3.Bucket, s3.historyKey(backupPath))
+}
+
+// RestoreFile copies the backup object of a history back to working zone.
+// A deleted file is undeleted, the content of an existed file is copied to history zone first
+func (s3 *Storage) RestoreFile(history *database.FileHistory) (*database.File, error) {
+	if !history.HasBackup() {
+		return nil, storages.ErrFileNotFound
+	}
+	file, err := s3.db.GetFileByIDUnscoped(history.FileID)
+	if err != nil {
+		return nil, err
+	}
+	backup, err := s3.client.GetObject(s3.Bucket, s3.historyKey(history.BackupPath), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer backup.Close()
+	if _, err := backup.Stat(); err != nil {
+		return nil, translateError(err)
+	}
+	if file.DeletedAt == nil {
+		if _, err := s3.replaceFile(file, backup, s3.db.RestoreFile); err != nil {
+			return nil, err
+		}
+		return file, nil
+	}
+
+	// Undelete the file, its path must not be taken by another file
+	workingKey := s3.workingKey(file.Fullname)
+	existed, err := s3.exists(workingKey)
+	if err != nil {
+		return nil, err
+	}
+	if existed {
+		return nil, storages.ErrFileExisted
+	}
+	if err := s3.copyObject(s3.historyKey(history.BackupPath), workingKey); err != nil {
+		return nil, err
+	}
+	meta, err := s3.readMetadata(file.Fullname)
+	if err == nil {
+		meta.Apply(file)
+		err = s3.db.RestoreFile(file, "")
+	}
+	if err != nil {
+		s3.client.RemoveObject(s3.Bucket, workingKey)
+		return nil, err
+	}
+	return file, nil
+}
+
+// RenameFile in bucket
+func (s3 *Storage) RenameFile(path, newName string) (string, error) {
+	file, err := s3.db.GetFileByName(path)
+	if err != nil {
+		return "", err
+	}
+	if newName, err = s3.Names.Clean(newName); err != nil {
+		return "", err
+	}
+	oldKey := s3.workingKey(path)
+	newKey := s3.workingKey(newName)
+	existed, err := s3.exists(newKey)
+	if err != nil {
+		return "", err
+	}
+	if existed {
+		return "", storages.ErrFileExisted
+	}
+	if err := s3.copyObject(oldKey, newKey); err != nil {
+		return "", err
+	}
+	if err := s3.client.RemoveObject(s3.Bucket, oldKey); err != nil {
+		s3.client.RemoveObject(s3.Bucket, newKey)
+		return "", err
+	}
+
+	// Save rename action to database.
+	// If failed to save action, rename to the origin one
+	if err := s3.db.RenameFile(file, newName); err != nil {
+		if cpErr := s3.copyObject(newKey, oldKey); cpErr == nil {
+			s3.client.RemoveObject(s3.Bucket, newKey)
+		}
+		return "", err
+	}
+	return newName, nil
+}
+
+// CopyFile to newName by server side copy
+func (s3 *Storage) CopyFile(path, newName string) (*database.File, error) {
+	source, err := s3.db.GetFileByName(path)
+	if err != nil {
+		return nil, err
+	}
+	if newName, err = s3.Names.Clean(newName); err != nil {
+		return nil, err
+	}
+	newKey := s3.workingKey(newName)
+	existed, err := s3.exists(newKey)
+	if err != nil {
+		return nil, err
+	}
+	if existed {
+		return nil, storages.ErrFileExisted
+	}
+	if err := s3.copyObject(s3.workingKey(path), newKey); err != nil {
+		return nil, err
+	}
+	fileModel := database.File{Fullname: newName}
+	fileModel.SetContent(source)
+	if err := s3.db.CreateFile(&fileModel); err != nil {
+		s3.client.RemoveObject(s3.Bucket, newKey)
+		return nil, err
+	}
+	return &fileModel, nil
+}
+
+// DeleteFile will copy the object to history zone, then remove the object in working zone
+// return the backup path and error if exists
+func (s3 *Storage) DeleteFile(path string) (string, error) {
+	file, err := s3.db.GetFileByName(path)
+	if err != nil {
+		return "", err
+	}
+	backupPath, err := s3.copyToHistory(path)
+	if err != nil {
+		return "", err
+	}
+	workingKey := s3.workingKey(path)
+	if err := s3.client.RemoveObject(s3.Bucket, workingKey); err != nil {
+		s3.client.RemoveObject(s3.Bucket, s3.historyKey(backupPath))
+		return "", err
+	}
+	// If can not save to database, copy the backup back to working zone
+	if err := s3.db.DeleteFile(file, backupPath); err != nil {
+		if cpErr := s3.copyObject(s3.historyKey(backupPath), workingKey); cpErr != nil {
+			log.Printf("Can not restore %s: %v", path, cpErr)
+		}
+		return "", err
+	}
+	return backupPath, nil
+}
+
+// GetImage streams and decodes an image from bucket
+func (s3 *Storage) GetImage(path string) (image.Image, error) {
+	if !s3.IsValidExt(extension(path)) {
+		return nil, storages.ErrFileExtInvalid
+	}
+	obj, err := s3.client.GetObject(s3.Bucket, s3.workingKey(path), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer obj.Close()
+	// Stat the object first, decoder hides not found error of reading
+	if _, err := obj.Stat(); err != nil {
+		return nil, translateError(err)
+	}
+	img, _, err := image.Decode(obj)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return img, nil
+}
+
+// ListFiles calls fn with client path of every object in working zone
+func (s3 *Storage) ListFiles(fn func(path string) error) error {
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+	prefix := ""
+	if s3.WorkingPrefix != "" {
+		prefix = strings.TrimSuffix(s3.WorkingPrefix, "/") + "/"
+	}
+	historyPrefix := strings.TrimSuffix(s3.HistoryPrefix, "/") + "/"
+	for obj := range s3.client.ListObjectsV2(s3.Bucket, prefix, true, doneCh) {
+		if obj.Err != nil {
+			return obj.Err
+		}
+		// History zone is inside working zone if working prefix is empty
+		if prefix == "" && strings.HasPrefix(obj.Key, historyPrefix) {
+			continue
+		}
+		if err := fn(strings.TrimPrefix(obj.Key, prefix)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Open an object in working zone for serving
+func (s3 *Storage) Open(name string) (http.File, error) {
+	clientPath := cleanPath(name)
+	if clientPath == "" {
+		return nil, os.ErrNotExist
+	}
+	return s3.openObject(s3.workingKey(clientPath))
+}
+
+// OpenHistory opens an object in history zone
+func (s3 *Storage) OpenHistory(backupPath string) (http.File, error) {
+	clientPath := cleanPath(backupPath)
+	if clientPath == "" {
+		return nil, os.ErrNotExist
+	}
+	return s3.openObject(s3.historyKey(clientPath))
+}
+
+// PurgeHistory removes an object from history zone
+func (s3 *Storage) PurgeHistory(backupPath string) error {
+	return s3.client.RemoveObject(s3.Bucket, s3.historyKey(cleanPath(backupPath)))
+}
+
+func (s3 *Storage) openObject(key string) (http.File, error) {
+	info, err := s3.client.StatObject(s3.Bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, os.ErrNotExist
+		}
+		return nil, err
+	}
+	obj, err := s3.client.GetObject(s3.Bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return &objectFile{Object: obj, info: info}, nil
+}
+
+//IsValidExt return true if file extension is a valid extension, extensions are case insensitive
+func (s3 *Storage) IsValidExt(ext string) bool {
+	for _, item := range s3.ValidExts {
+		if strings.EqualFold(item, ext) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,255 @@
+package s3storage
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/thanhtuan260593/file-server/database"
+	"github.com/thanhtuan260593/file-server/storages"
+)
+
+var store *Storage
+var fakeServer *httptest.Server
+var dbURL = "postgres://file-server:@:54321/file-server?sslmode=disable"
+var addedFile = "folder/added.png"
+
+func newTestStorage(db *database.DB) *Storage {
+	endpoint, _ := url.Parse(fakeServer.URL)
+	config := NewConfig()
+	config.Endpoint = endpoint.Host
+	config.AccessKey = "minio"
+	config.SecretKey = "minio123"
+	config.Bucket = "file-server"
+	config.UseSSL = false
+	s3, err := NewStorage(db, config)
+	if err != nil {
+		panic(err)
+	}
+	return s3
+}
+
+// reset connects to the test database, tests which do not need database use newTestStorage(nil)
+func reset() {
+	fakeServer.Config.Handler = newFakeS3()
+	store = newTestStorage(database.NewClean(dbURL))
+}
+
+func testImage(c color.Color) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 3; y++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buffer bytes.Buffer
+	png.Encode(&buffer, img)
+	return buffer.Bytes()
+}
+
+func TestMain(m *testing.M) {
+	fakeServer = httptest.NewServer(newFakeS3())
+	code := m.Run()
+	fakeServer.Close()
+	os.Exit(code)
+}
+
+func TestPutAndOpenObject(t *testing.T) {
+	s3 := newTestStorage(nil)
+	data := testImage(color.White)
+	if err := s3.putObject(addedFile, bytes.NewBuffer(data)); err != nil {
+		t.Error(err)
+		return
+	}
+	f, err := s3.Open("/" + addedFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	assert.Nil(t, err)
+	assert.Equal(t, "added.png", info.Name())
+	assert.Equal(t, int64(len(data)), info.Size())
+	read, err := ioutil.ReadAll(f)
+	assert.Nil(t, err)
+	assert.Equal(t, data, read)
+
+	_, err = s3.Open("/missing.png")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestGetImage(t *testing.T) {
+	s3 := newTestStorage(nil)
+	if err := s3.putObject(addedFile, bytes.NewReader(testImage(color.Black))); err != nil {
+		t.Error(err)
+		return
+	}
+	img, err := s3.GetImage(addedFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.Equal(t, 4, img.Bounds().Dx())
+	assert.Equal(t, 3, img.Bounds().Dy())
+
+	_, err = s3.GetImage("missing.png")
+	assert.Equal(t, storages.ErrFileNotFound, err)
+	_, err = s3.GetImage("added.txt")
+	assert.Equal(t, storages.ErrFileExtInvalid, err)
+}
+
+func TestCopyToHistory(t *testing.T) {
+	s3 := newTestStorage(nil)
+	if err := s3.putObject(addedFile, bytes.NewReader(testImage(color.White))); err != nil {
+		t.Error(err)
+		return
+	}
+	first, err := s3.copyToHistory(addedFile)
+	assert.Nil(t, err)
+	assert.Equal(t, addedFile, first)
+	second, err := s3.copyToHistory(addedFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "folder/added_1.png", second)
+	existed, err := s3.exists(s3.historyKey(second))
+	assert.Nil(t, err)
+	assert.True(t, existed)
+
+	f, err := s3.OpenHistory(second)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer f.Close()
+	read, _ := ioutil.ReadAll(f)
+	assert.Equal(t, testImage(color.White), read)
+	_, err = s3.OpenHistory("folder/missing.png")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestListFiles(t *testing.T) {
+	fakeServer.Config.Handler = newFakeS3()
+	s3 := newTestStorage(nil)
+	for _, name := range []string{addedFile, "b.png"} {
+		if err := s3.putObject(name, bytes.NewReader(testImage(color.White))); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if _, err := s3.copyToHistory("b.png"); err != nil {
+		t.Error(err)
+		return
+	}
+	var listed []string
+	err := s3.ListFiles(func(path string) error {
+		listed = append(listed, path)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b.png", addedFile}, listed)
+}
+
+func TestAddFile(t *testing.T) {
+	reset()
+	if _, err := store.AddFile(bytes.NewReader(testImage(color.White)), addedFile); err != nil {
+		t.Error(err)
+		return
+	}
+	_, err := store.AddFile(bytes.NewReader(testImage(color.White)), addedFile)
+	assert.Equal(t, storages.ErrFileExisted, err)
+}
+
+func TestReplaceFile(t *testing.T) {
+	t.Run("Create file to replace", TestAddFile)
+	replaced := testImage(color.Black)
+	backupPath, err := store.ReplaceFile(addedFile, bytes.NewReader(replaced))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	existed, _ := store.exists(store.historyKey(backupPath))
+	assert.True(t, existed)
+	f, err := store.Open(addedFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer f.Close()
+	read, _ := ioutil.ReadAll(f)
+	assert.Equal(t, replaced, read)
+}
+
+func TestReplaceFileRestoresOnFailure(t *testing.T) {
+	fakeServer.Config.Handler = newFakeS3()
+	s3 := newTestStorage(nil)
+	original := testImage(color.White)
+	if err := s3.putObject(addedFile, bytes.NewReader(original)); err != nil {
+		t.Error(err)
+		return
+	}
+	failure := errors.New("save-failed")
+	file := &database.File{Fullname: addedFile}
+	_, err := s3.replaceFile(file, bytes.NewReader(testImage(color.Black)), func(*database.File, string) error {
+		return failure
+	})
+	assert.Equal(t, failure, err)
+
+	f, err := s3.Open(addedFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer f.Close()
+	read, _ := ioutil.ReadAll(f)
+	assert.Equal(t, original, read)
+	existed, _ := s3.exists(s3.historyKey(addedFile))
+	assert.False(t, existed, "the backup is removed once restored")
+
+}
+
+func TestRenameFile(t *testing.T) {
+	t.Run("Create file to rename", TestAddFile)
+	if _, err := store.RenameFile(addedFile, "renamed.png"); err != nil {
+		t.Error(err)
+		return
+	}
+	existed, _ := store.exists(store.workingKey(addedFile))
+	assert.False(t, existed)
+	existed, _ = store.exists(store.workingKey("renamed.png"))
+	assert.True(t, existed)
+}
+
+func TestCopyFile(t *testing.T) {
+	t.Run("Create file to copy", TestAddFile)
+	copied, err := store.CopyFile(addedFile, "copied.png")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	existed, _ := store.exists(store.workingKey(copied.Fullname))
+	assert.True(t, existed)
+	existed, _ = store.exists(store.workingKey(addedFile))
+	assert.True(t, existed)
+}
+
+func TestRemoveFile(t *testing.T) {
+	t.Run("Create file to remove", TestAddFile)
+	backupPath, err := store.DeleteFile(addedFile)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	existed, _ := store.exists(store.workingKey(addedFile))
+	assert.False(t, existed)
+	existed, _ = store.exists(store.historyKey(backupPath))
+	assert.True(t, existed)
+}
@@ -0,0 +1,158 @@
+package s3storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v6"
+	"github.com/thanhtuan260593/file-server/storages"
+)
+
+func cleanPath(clientPath string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(clientPath)), "/")
+}
+
+func extension(clientPath string) string {
+	return path.Ext(clientPath)
+}
+
+func (s3 *Storage) workingKey(clientPath string) string {
+	return path.Join(s3.WorkingPrefix, cleanPath(clientPath))
+}
+
+func (s3 *Storage) historyKey(clientPath string) string {
+	return path.Join(s3.HistoryPrefix, cleanPath(clientPath))
+}
+
+func isNotFound(err error) bool {
+	code := minio.ToErrorResponse(err).Code
+	return code == "NoSuchKey" || code == "NotFound"
+}
+
+func translateError(err error) error {
+	if isNotFound(err) {
+		return storages.ErrFileNotFound
+	}
+	return err
+}
+
+func (s3 *Storage) exists(key string) (bool, error) {
+	_, err := s3.client.StatObject(s3.Bucket, key, minio.StatObjectOptions{})
+	if err == nil {
+		return true, nil
+	}
+	if isNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+func (s3 *Storage) putObject(clientPath string, reader io.Reader) error {
+	sized, size, err := readerSize(reader)
+	if err != nil {
+		return err
+	}
+	opts := minio.PutObjectOptions{ContentType: mime.TypeByExtension(extension(clientPath))}
+	_, err = s3.client.PutObject(s3.Bucket, s3.workingKey(clientPath), sized, size, opts)
+	return err
+}
+
+func (s3 *Storage) copyObject(srcKey, dstKey string) error {
+	dst, err := minio.NewDestinationInfo(s3.Bucket, dstKey, nil, nil)
+	if err != nil {
+		return err
+	}
+	return translateError(s3.client.CopyObject(dst, minio.NewSourceInfo(s3.Bucket, srcKey, nil)))
+}
+
+// readMetadata streams a working object to extract its metadata
+func (s3 *Storage) readMetadata(clientPath string) (*storages.Metadata, error) {
+	obj, err := s3.client.GetObject(s3.Bucket, s3.workingKey(clientPath), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer obj.Close()
+	return storages.ReadMetadata(clientPath, obj)
+}
+
+// copyToHistory copies a working object to an unused name in history zone
+// return the path of the copy relative to history zone
+func (s3 *Storage) copyToHistory(clientPath string) (string, error) {
+	clientPath = cleanPath(clientPath)
+	ext := extension(clientPath)
+	name := strings.TrimSuffix(clientPath, ext)
+	backupPath := clientPath
+	for count := 1; ; count++ {
+		existed, err := s3.exists(s3.historyKey(backupPath))
+		if err != nil {
+			return "", err
+		}
+		if !existed {
+			break
+		}
+		if count >= MaxDuplicateFile {
+			return "", storages.ErrFileExisted
+		}
+		backupPath = fmt.Sprintf("%v_%v%v", name, count, ext)
+	}
+	if err := s3.copyObject(s3.workingKey(clientPath), s3.historyKey(backupPath)); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
+
+// readerSize return the length of reader, buffer the reader if it can not seek
+func readerSize(reader io.Reader) (io.Reader, int64, error) {
+	if seeker, ok := reader.(io.Seeker); ok {
+		current, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, 0, err
+		}
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, 0, err
+		}
+		if _, err := seeker.Seek(current, io.SeekStart); err != nil {
+			return nil, 0, err
+		}
+		return reader, end - current, nil
+	}
+	var buffer bytes.Buffer
+	if _, err := io.Copy(&buffer, reader); err != nil {
+		return nil, 0, err
+	}
+	return &buffer, int64(buffer.Len()), nil
+}
+
+// objectFile serves an object as http.File
+type objectFile struct {
+	*minio.Object
+	info minio.ObjectInfo
+}
+
+func (f *objectFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, os.ErrInvalid
+}
+
+func (f *objectFile) Stat() (os.FileInfo, error) {
+	return objectInfo{f.info}, nil
+}
+
+// objectInfo describes an object as os.FileInfo
+type objectInfo struct {
+	info minio.ObjectInfo
+}
+
+func (o objectInfo) Name() string       { return path.Base(o.info.Key) }
+func (o objectInfo) Size() int64        { return o.info.Size }
+func (o objectInfo) Mode() os.FileMode  { return 0444 }
+func (o objectInfo) ModTime() time.Time { return o.info.LastModified }
+func (o objectInfo) IsDir() bool        { return false }
+func (o objectInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,69 @@
+package s3storage
+
+import (
+	"os"
+	"strconv"
+)
+
+//DriverName of s3 storage
+var DriverName = "s3"
+
+//DefaultWorkingPrefix global value
+var DefaultWorkingPrefix = "images"
+
+//DefaultHistoryPrefix global value
+var DefaultHistoryPrefix = "_history"
+
+//DefaultRegion global value
+var DefaultRegion = "us-east-1"
+
+//MaxDuplicateFile value
+var MaxDuplicateFile = 2020
+
+//PngExt, SvgExt, JpgExt, JpegExt, GifExt, WebpExt is extensions
+var (
+	PngExt  = ".png"
+	SvgExt  = ".svg"
+	JpgExt  = ".jpg"
+	JpegExt = ".jpeg"
+	GifExt  = ".gif"
+	WebpExt = ".webp"
+)
+
+//Config of s3 storage
+type Config struct {
+	Endpoint      string
+	AccessKey     string
+	SecretKey     string
+	Region        string
+	Bucket        string
+	UseSSL        bool
+	WorkingPrefix string
+	HistoryPrefix string
+}
+
+//NewConfig read config from os enviroment
+func NewConfig() Config {
+	var config = Config{
+		Region:        DefaultRegion,
+		WorkingPrefix: DefaultWorkingPrefix,
+		HistoryPrefix: DefaultHistoryPrefix,
+	}
+	config.Endpoint = os.Getenv("S3_ENDPOINT")
+	config.AccessKey = os.Getenv("S3_ACCESS_KEY")
+	config.SecretKey = os.Getenv("S3_SECRET_KEY")
+	config.Bucket = os.Getenv("S3_BUCKET")
+	if region := os.Getenv("S3_REGION"); region != "" {
+		config.Region = region
+	}
+	if ssl, err := strconv.ParseBool(os.Getenv("S3_USE_SSL")); err == nil {
+		config.UseSSL = ssl
+	}
+	if w := os.Getenv("S3_WORKING_PREFIX"); w != "" {
+		config.WorkingPrefix = w
+	}
+	if w := os.Getenv("S3_HISTORY_PREFIX"); w != "" {
+		config.HistoryPrefix = w
+	}
+	return config
+}
@@ -0,0 +1,98 @@
+package storages
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/thanhtuan260593/file-server/database"
+)
+
+//Expected errors
+var (
+	ErrFileNotFound   = errors.New("file-not-found")
+	ErrFileNotRead    = errors.New("file-not-read")
+	ErrFileExtInvalid = errors.New("file-ext-invalid")
+	ErrFileExisted    = errors.New("file-existed")
+	ErrUnknownDriver  = errors.New("storage-driver-unknown")
+)
+
+//DefaultDriver is used when no driver is configured
+var DefaultDriver = "local"
+
+// Backend is implemented by every storage driver.
+// Paths are client paths, relative to the root of the storage.
+type Backend interface {
+	// AddFile stores a new file and tracks it in database
+	AddFile(reader io.Reader, fileName string) (*database.File, error)
+	// ReplaceFile overwrites the content of an existed file, return the backup path
+	ReplaceFile(path string, reader io.Reader) (string, error)
+	// RenameFile moves a file to newName, return the new name
+	RenameFile(path, newName string) (string, error)
+	// CopyFile duplicates a file to newName and tracks the copy in database
+	CopyFile(path, newName string) (*database.File, error)
+	// DeleteFile moves a file to history zone, return the backup path
+	DeleteFile(path string) (string, error)
+	// RestoreFile puts the backup content of a history back to its file, undeleting the file if it was deleted
+	RestoreFile(history *database.FileHistory) (*database.File, error)
+	// GetImage decodes an image from storage
+	GetImage(path string) (image.Image, error)
+	// OpenHistory opens a backup in history zone by the backup path returned by ReplaceFile or DeleteFile
+	OpenHistory(backupPath string) (http.File, error)
+	// PurgeHistory removes a backup from history zone, removing a missing backup is not an error
+	PurgeHistory(backupPath string) error
+	// FileSystem serves the working files
+	http.FileSystem
+}
+
+// Driver creates a backend on top of the database
+type Driver func(db *database.DB) (Backend, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]Driver)
+)
+
+// Register makes a storage driver available by the provided name.
+// If Register is called twice with the same name or if driver is nil, it panics.
+func Register(name string, driver Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if driver == nil {
+		panic("storages: Register driver is nil")
+	}
+	if _, dup := drivers[name]; dup {
+		panic("storages: Register called twice for driver " + name)
+	}
+	drivers[name] = driver
+}
+
+// Drivers return a sorted list of registered driver names
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open a backend by its driver name
+func Open(name string, db *database.DB) (Backend, error) {
+	if name == "" {
+		name = DefaultDriver
+	}
+	driversMu.RLock()
+	driver, ok := drivers[name]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%v: %w", name, ErrUnknownDriver)
+	}
+	return driver(db)
+}
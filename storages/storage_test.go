@@ -0,0 +1,59 @@
+package storages
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/thanhtuan260593/file-server/database"
+)
+
+type nopBackend struct {
+	Backend
+}
+
+func TestOpenRegisteredDriver(t *testing.T) {
+	Register("nop", func(db *database.DB) (Backend, error) {
+		return &nopBackend{}, nil
+	})
+	backend, err := Open("nop", nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, ok := backend.(*nopBackend); !ok {
+		t.Errorf("unexpected backend %T", backend)
+	}
+}
+
+func TestOpenUnknownDriver(t *testing.T) {
+	if _, err := Open("unknown", nil); !errors.Is(err, ErrUnknownDriver) {
+		t.Errorf("expected %v, got %v", ErrUnknownDriver, err)
+	}
+}
+
+func TestRegisterTwiceShouldPanic(t *testing.T) {
+	Register("twice", func(db *database.DB) (Backend, error) { return nil, nil })
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	Register("twice", func(db *database.DB) (Backend, error) { return nil, nil })
+}
+
+func TestRetentionPolicy(t *testing.T) {
+	if (RetentionPolicy{}).Enabled() {
+		t.Error("empty policy should keep backups forever")
+	}
+	now := time.Date(2020, 6, 10, 0, 0, 0, 0, time.UTC)
+	if before := (RetentionPolicy{KeepVersions: 3}).expiredBefore(now); !before.IsZero() {
+		t.Errorf("unexpected expiry %v", before)
+	}
+	if before := (RetentionPolicy{KeepDays: 7}).expiredBefore(now); !before.Equal(time.Date(2020, 6, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected expiry %v", before)
+	}
+	if n, err := Purge(nil, &nopBackend{}, RetentionPolicy{}); n != 0 || err != nil {
+		t.Errorf("disabled policy should purge nothing, got %v, %v", n, err)
+	}
+}
@@ -0,0 +1,158 @@
+package storages
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"image"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Upload errors, each failed check has its own error
+var (
+	ErrUploadTooLarge      = errors.New("upload-too-large")
+	ErrFileContentMismatch = errors.New("file-content-mismatch")
+	ErrImageNotDecodable   = errors.New("image-not-decodable")
+	ErrImageTooManyPixels  = errors.New("image-too-many-pixels")
+)
+
+// Default limits of uploads
+var (
+	DefaultMaxUploadBytes int64   = 32 << 20
+	DefaultMaxMegapixels  float64 = 50
+)
+
+// uploadContentTypes are the content types expected for the extensions of uploaded files
+var uploadContentTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".svg":  "image/svg+xml",
+}
+
+// UploadPolicy limits the files accepted from clients, zero limits are disabled
+type UploadPolicy struct {
+	MaxBytes      int64
+	MaxMegapixels float64
+}
+
+// NewUploadPolicy read IMAGE_MAX_UPLOAD_BYTES and IMAGE_MAX_MEGAPIXELS from os enviroment
+func NewUploadPolicy() UploadPolicy {
+	policy := UploadPolicy{
+		MaxBytes:      DefaultMaxUploadBytes,
+		MaxMegapixels: DefaultMaxMegapixels,
+	}
+	if v, err := strconv.ParseInt(os.Getenv("IMAGE_MAX_UPLOAD_BYTES"), 10, 64); err == nil && v >= 0 {
+		policy.MaxBytes = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("IMAGE_MAX_MEGAPIXELS"), 64); err == nil && v >= 0 {
+		policy.MaxMegapixels = v
+	}
+	return policy
+}
+
+// Validate checks an upload is an image of the type told by the extension of name.
+// The magic bytes must match the extension, the image must decode within the limits of the policy.
+// reader is consumed.
+func (p UploadPolicy) Validate(name string, reader io.Reader) error {
+	expected, ok := uploadContentTypes[strings.ToLower(filepath.Ext(name))]
+	if !ok {
+		return ErrFileExtInvalid
+	}
+	limited := &limitedReader{r: reader, n: p.MaxBytes}
+	if p.MaxBytes > 0 {
+		reader = limited
+	}
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(reader, head)
+	if limited.n < 0 {
+		return ErrUploadTooLarge
+	}
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	head = head[:n]
+	if detectContentType(name, head) != expected {
+		return ErrFileContentMismatch
+	}
+	content := io.MultiReader(bytes.NewReader(head), reader)
+	if expected == "image/svg+xml" {
+		err = decodeSVG(content)
+	} else {
+		err = p.decodeImage(content)
+	}
+	if err == nil {
+		// the decoder may stop before the end of the upload
+		_, err = io.Copy(ioutil.Discard, content)
+	}
+	switch {
+	case limited.n < 0:
+		// decoders report the failed read in their own way
+		return ErrUploadTooLarge
+	case err == ErrImageTooManyPixels:
+		return err
+	case err != nil:
+		return ErrImageNotDecodable
+	}
+	return nil
+}
+
+// decodeImage checks the dimensions before the pixels are decoded
+func (p UploadPolicy) decodeImage(reader io.Reader) error {
+	var head bytes.Buffer
+	config, _, err := image.DecodeConfig(io.TeeReader(reader, &head))
+	if err != nil {
+		return err
+	}
+	if p.MaxMegapixels > 0 && float64(config.Width)*float64(config.Height) > p.MaxMegapixels*1e6 {
+		return ErrImageTooManyPixels
+	}
+	_, _, err = image.Decode(io.MultiReader(&head, reader))
+	return err
+}
+
+// decodeSVG checks the content is a xml document with a svg root
+func decodeSVG(reader io.Reader) error {
+	decoder := xml.NewDecoder(reader)
+	root := true
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF && !root {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if start, ok := token.(xml.StartElement); ok && root {
+			if start.Name.Local != "svg" {
+				return ErrImageNotDecodable
+			}
+			root = false
+		}
+	}
+}
+
+// limitedReader fails with ErrUploadTooLarge once more than n bytes are read
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	if l.n -= int64(n); l.n < 0 {
+		return n, ErrUploadTooLarge
+	}
+	return n, err
+}
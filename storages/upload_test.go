@@ -0,0 +1,54 @@
+package storages
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodePNG(width, height int) []byte {
+	var b bytes.Buffer
+	png.Encode(&b, image.NewGray(image.Rect(0, 0, width, height)))
+	return b.Bytes()
+}
+
+func TestUploadPolicyValidate(t *testing.T) {
+	small := encodePNG(10, 10)
+	policy := UploadPolicy{MaxBytes: 1 << 20, MaxMegapixels: 1}
+	cases := []struct {
+		name     string
+		content  []byte
+		policy   UploadPolicy
+		expected error
+	}{
+		{"a.png", small, policy, nil},
+		{"a.PNG", small, policy, nil},
+		{"a.svg", []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"></svg>`), policy, nil},
+		{"a.exe", small, policy, ErrFileExtInvalid},
+		{"a.jpg", small, policy, ErrFileContentMismatch},
+		{"a.png", []byte("not an image"), policy, ErrFileContentMismatch},
+		{"a.png", small[:40], policy, ErrImageNotDecodable},
+		{"a.svg", []byte(`<?xml version="1.0"?><html></html>`), policy, ErrImageNotDecodable},
+		{"a.svg", []byte(`<svg><g></svg>`), policy, ErrImageNotDecodable},
+		{"a.png", encodePNG(1001, 1000), policy, ErrImageTooManyPixels},
+		{"a.png", encodePNG(1001, 1000), UploadPolicy{}, nil},
+		{"a.png", small, UploadPolicy{MaxBytes: int64(len(small) - 1)}, ErrUploadTooLarge},
+		{"a.png", small, UploadPolicy{MaxBytes: int64(len(small))}, nil},
+		{"a.png", small, UploadPolicy{MaxBytes: 10}, ErrUploadTooLarge},
+	}
+	for _, c := range cases {
+		err := c.policy.Validate(c.name, bytes.NewReader(c.content))
+		assert.Equal(t, c.expected, err, "%s %d bytes", c.name, len(c.content))
+	}
+}
+
+func TestUploadPolicyReadsWholeUpload(t *testing.T) {
+	// content after the image counts for the size limit
+	content := append(encodePNG(10, 10), []byte(strings.Repeat("x", 100))...)
+	policy := UploadPolicy{MaxBytes: int64(len(content) - 1)}
+	assert.Equal(t, ErrUploadTooLarge, policy.Validate("a.png", bytes.NewReader(content)))
+}